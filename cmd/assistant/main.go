@@ -12,10 +12,18 @@ import (
 	"github.com/supportbot/supportbot-go/internal/config"
 	"github.com/supportbot/supportbot-go/internal/middleware"
 	"github.com/supportbot/supportbot-go/internal/model"
+	"github.com/supportbot/supportbot-go/internal/service"
+	"github.com/supportbot/supportbot-go/internal/service/agent"
+	"github.com/supportbot/supportbot-go/internal/tools"
 	"github.com/supportbot/supportbot-go/pkg/logger"
+	"github.com/supportbot/supportbot-go/pkg/redis"
 	"go.uber.org/zap"
 )
 
+// agentSystemPrompt 指导模型在需要时调用工具查询真实数据，而不是凭空编造答案
+const agentSystemPrompt = `你是专业客服助手。遇到订单状态、商品库存、知识库类问题时，
+请调用相应工具获取真实数据，再据此给用户友好、简洁的回复；不要凭空编造信息。`
+
 type AssistantRequest struct {
 	UserID   int64  `json:"userId"`
 	Question string `json:"question"`
@@ -38,8 +46,38 @@ func main() {
 
 	zapLogger.Info("assistant 服务启动中...")
 
-	// 初始化 LLM 客户端
-	llmClient := client.NewDashScopeClient(cfg.DashScope.APIKey, cfg.DashScope.Model, zapLogger)
+	// 初始化 LLM 客户端：Agent 依赖 DashScope 特有的 ChatWithTools 函数调用接口，
+	// 不走通用的 client.LLMProvider 抽象
+	llmClient := client.NewDashScopeClient(cfg.LLM.APIKey, cfg.LLM.Model, zapLogger)
+
+	// 初始化工具注册中心并注册内置工具与 Agent 工具
+	toolRegistry := tools.NewRegistry(zapLogger)
+	if err := tools.RegisterBuiltinTools(toolRegistry, zapLogger); err != nil {
+		log.Fatalf("注册内置工具失败: %v", err)
+	}
+	if err := tools.RegisterAgentTools(toolRegistry, cfg.Services.KnowledgeRAG, zapLogger); err != nil {
+		log.Fatalf("注册 Agent 工具失败: %v", err)
+	}
+
+	// 初始化工具调用 Agent
+	agentInstance := agent.NewAgent(llmClient, toolRegistry, agentSystemPrompt, zapLogger)
+
+	// 配置了 Redis 时接入对话记忆（摘要 + 滚动窗口），使 Agent 能看到同一用户此前
+	// 的问答；未配置则跳过，Run 按无历史上下文处理
+	var memoryService *service.MemoryService
+	if cfg.Redis.Host != "" {
+		redisClient, err := redis.NewRedisClient(cfg.Redis)
+		if err != nil {
+			zapLogger.Warn("连接 Redis 失败，跳过对话记忆", zap.Error(err))
+		} else {
+			llmProvider, err := client.NewLLMProvider(cfg.LLM, zapLogger)
+			if err != nil {
+				zapLogger.Warn("初始化 LLM Provider 失败，跳过对话记忆", zap.Error(err))
+			} else {
+				memoryService = service.NewMemoryService(redisClient, llmProvider, zapLogger)
+			}
+		}
+	}
 
 	// 业务服务 URL
 	imDemoURL := cfg.Services.IMDemo
@@ -61,7 +99,7 @@ func main() {
 			zap.String("question", req.Question))
 
 		// 异步处理
-		go processRequest(req, llmClient, imDemoURL, zapLogger)
+		go processRequest(req, agentInstance, memoryService, imDemoURL, zapLogger)
 
 		c.JSON(200, gin.H{"status": "processing"})
 	})
@@ -79,28 +117,35 @@ func main() {
 	}
 }
 
-func processRequest(req AssistantRequest, llmClient *client.DashScopeClient, 
+func processRequest(req AssistantRequest, agentInstance *agent.Agent, memoryService *service.MemoryService,
 	imDemoURL string, logger *zap.Logger) {
-	
-	// 模拟业务查询结果
-	var businessResult string
-	switch req.Category {
-	case "product.inquiry":
-		businessResult = "商品名称：智能手表，价格：¥999.00，库存：充足"
-	case "order.status":
-		businessResult = "订单编号：20231218001，状态：配送中，预计明天送达"
-	default:
-		businessResult = "暂无相关信息"
+
+	// 取对话记忆（摘要 + 最近轮次），让 Agent 能看到同一用户此前的问答
+	var historyContext string
+	if memoryService != nil {
+		var err error
+		historyContext, err = memoryService.BuildContext(req.UserID, 0)
+		if err != nil {
+			logger.Warn("读取对话记忆失败，Agent 将不带历史上下文", zap.Int64("userId", req.UserID), zap.Error(err))
+		}
 	}
 
-	// 使用 LLM 生成友好回复
-	systemPrompt := "你是专业客服助手，根据查询结果给用户友好的回复。"
-	prompt := fmt.Sprintf("查询结果：%s\n用户问题：%s\n请回复用户。", businessResult, req.Question)
-	
-	response, err := llmClient.SimpleChat(systemPrompt, prompt)
+	// 交给 Agent 执行工具调用循环，按需查询订单/库存/知识库后再生成回复
+	response, trace, err := agentInstance.Run(req.Question, historyContext)
 	if err != nil {
-		logger.Error("LLM 调用失败", zap.Error(err))
-		response = businessResult
+		logger.Error("Agent 处理失败", zap.Error(err))
+		response = "抱歉，暂时无法处理您的问题，请稍后重试。"
+	}
+	logger.Info("Agent 执行轨迹",
+		zap.Int64("userId", req.UserID),
+		zap.Int("steps", len(trace)),
+		zap.Any("trace", trace))
+
+	// 用户问题已经在 question-classifier 路由前记过，这里只补记回复那一半
+	if memoryService != nil {
+		if err := memoryService.AppendTurn(req.UserID, "assistant", response); err != nil {
+			logger.Error("记录对话记忆失败", zap.Int64("userId", req.UserID), zap.Error(err))
+		}
 	}
 
 	// 发送到 im-demo
@@ -124,4 +169,3 @@ func sendToIM(userID int64, content string, imDemoURL string, logger *zap.Logger
 
 	logger.Info("回复已发送", zap.Int64("userId", userID))
 }
-
@@ -6,13 +6,17 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/supportbot/supportbot-go/internal/client"
 	"github.com/supportbot/supportbot-go/internal/config"
 	"github.com/supportbot/supportbot-go/internal/middleware"
 	"github.com/supportbot/supportbot-go/internal/model"
+	"github.com/supportbot/supportbot-go/internal/service"
 	"github.com/supportbot/supportbot-go/pkg/logger"
+	"github.com/supportbot/supportbot-go/pkg/redis"
 	"go.uber.org/zap"
 )
 
@@ -35,7 +39,22 @@ func main() {
 
 	zapLogger.Info("general-chat 服务启动中...")
 
-	llmClient := client.NewDashScopeClient(cfg.DashScope.APIKey, cfg.DashScope.Model, zapLogger)
+	llmProvider, err := client.NewLLMProvider(cfg.LLM, zapLogger)
+	if err != nil {
+		zapLogger.Fatal("初始化 LLM Provider 失败", zap.Error(err))
+	}
+
+	// 配置了 Redis 时接入对话记忆（摘要 + 滚动窗口）；未配置则跳过，退化为无历史上下文
+	var memoryService *service.MemoryService
+	if cfg.Redis.Host != "" {
+		redisClient, err := redis.NewRedisClient(cfg.Redis)
+		if err != nil {
+			zapLogger.Warn("连接 Redis 失败，跳过对话记忆", zap.Error(err))
+		} else {
+			memoryService = service.NewMemoryService(redisClient, llmProvider, zapLogger)
+		}
+	}
+
 	imDemoURL := cfg.Services.IMDemo
 
 	r := gin.Default()
@@ -52,7 +71,7 @@ func main() {
 			zap.Int64("userId", req.UserID),
 			zap.String("question", req.Question))
 
-		go processChat(req, llmClient, imDemoURL, zapLogger)
+		go processChat(req, llmProvider, memoryService, imDemoURL, zapLogger)
 		c.JSON(200, gin.H{"status": "processing"})
 	})
 
@@ -68,34 +87,73 @@ func main() {
 	}
 }
 
-func processChat(req ChatRequest, llmClient *client.DashScopeClient, 
+// processChat 流式调用 LLM，每收到一段增量文本就立即转发给 im-demo，而不是像
+// 之前那样攒完整回复再一次性发送，用户等待 5-15s 静默期的问题由此缓解。
+func processChat(req ChatRequest, llmProvider client.LLMProvider, memoryService *service.MemoryService,
 	imDemoURL string, logger *zap.Logger) {
-	
+
 	systemPrompt := "你是一个友好的客服助手，负责与用户进行日常对话。"
-	response, err := llmClient.SimpleChat(systemPrompt, req.Question)
+	streamID := uuid.New().String()
+	seq := 0
+
+	// 取对话记忆（摘要 + 最近轮次），让模型能看到同一用户此前的问答
+	var historyContext string
+	if memoryService != nil {
+		var err error
+		historyContext, err = memoryService.BuildContext(req.UserID, 0)
+		if err != nil {
+			logger.Warn("读取对话记忆失败，本次对话将不带历史上下文", zap.Int64("userId", req.UserID), zap.Error(err))
+		}
+	}
+
+	userMessage := req.Question
+	if historyContext != "" {
+		userMessage = fmt.Sprintf("%s\n用户问题：%s", historyContext, req.Question)
+	}
+
+	var fullResponse strings.Builder
+	err := llmProvider.ChatStream([]client.Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: userMessage},
+	}, func(delta client.Delta) error {
+		fullResponse.WriteString(delta.Content)
+		sendStreamChunkToIM(req.UserID, streamID, seq, delta.Content, false, imDemoURL, logger)
+		seq++
+		return nil
+	})
+
 	if err != nil {
-		logger.Error("LLM 调用失败", zap.Error(err))
-		response = "抱歉，我现在有点忙，请稍后再试。"
+		logger.Error("LLM 流式调用失败", zap.Error(err))
+		sendStreamChunkToIM(req.UserID, streamID, seq, "抱歉，我现在有点忙，请稍后再试。", true, imDemoURL, logger)
+		return
 	}
 
-	sendToIM(req.UserID, response, imDemoURL, logger)
+	sendStreamChunkToIM(req.UserID, streamID, seq, "", true, imDemoURL, logger)
+
+	// 用户问题已经在 question-classifier 路由前记过，这里只补记回复那一半
+	if memoryService != nil {
+		if err := memoryService.AppendTurn(req.UserID, "assistant", fullResponse.String()); err != nil {
+			logger.Error("记录对话记忆失败", zap.Int64("userId", req.UserID), zap.Error(err))
+		}
+	}
 }
 
-func sendToIM(userID int64, content string, imDemoURL string, logger *zap.Logger) {
+// sendStreamChunkToIM 把一帧流式增量转发给 im-demo 的流式接口
+func sendStreamChunkToIM(userID int64, streamID string, seq int, content string, done bool, imDemoURL string, logger *zap.Logger) {
 	aiResp := model.AIResponseRequest{
-		UserID:  userID,
-		Content: content,
-		Source:  "general-chat",
+		UserID:   userID,
+		Content:  content,
+		Source:   "general-chat",
+		StreamID: streamID,
+		Seq:      seq,
+		Done:     done,
 	}
 
 	jsonData, _ := json.Marshal(aiResp)
-	resp, err := http.Post(imDemoURL+"/api/ai-response/send", "application/json", bytes.NewBuffer(jsonData))
+	resp, err := http.Post(imDemoURL+"/api/ai-response/stream", "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
-		logger.Error("发送到 im-demo 失败", zap.Error(err))
+		logger.Error("发送增量到 im-demo 失败", zap.Error(err))
 		return
 	}
 	defer resp.Body.Close()
-
-	logger.Info("回复已发送", zap.Int64("userId", userID))
 }
-
@@ -5,11 +5,13 @@ import (
 	"log"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/supportbot/supportbot-go/internal/config"
 	"github.com/supportbot/supportbot-go/internal/handler"
 	"github.com/supportbot/supportbot-go/internal/middleware"
 	"github.com/supportbot/supportbot-go/internal/service"
 	"github.com/supportbot/supportbot-go/pkg/logger"
+	"github.com/supportbot/supportbot-go/pkg/redis"
 	"go.uber.org/zap"
 )
 
@@ -33,6 +35,23 @@ func main() {
 	sessionService := service.NewSessionService(zapLogger)
 	chatService := service.NewChatService(cfg.Services.QuestionClassifier, zapLogger)
 
+	// 配置了 Redis 时接入跨节点会话路由，使多副本部署下消息也能送达用户实际
+	// 连接的那个节点；未配置则跳过，退化为单机模式
+	if cfg.Redis.Host != "" {
+		redisClient, err := redis.NewRedisClient(cfg.Redis)
+		if err != nil {
+			zapLogger.Warn("连接 Redis 失败，跳过跨节点会话路由", zap.Error(err))
+		} else {
+			nodeID := uuid.New().String()
+			broker := service.NewRedisClusterBroker(redisClient)
+			if err := sessionService.SetClusterBroker(broker, nodeID); err != nil {
+				zapLogger.Warn("订阅跨节点会话频道失败，跳过跨节点会话路由", zap.Error(err))
+			} else {
+				zapLogger.Info("已接入跨节点会话路由", zap.String("nodeId", nodeID))
+			}
+		}
+	}
+
 	// 初始化处理器
 	wsHandler := handler.NewWebSocketHandler(sessionService, chatService, zapLogger)
 	apiHandler := handler.NewAPIHandler(sessionService, zapLogger)
@@ -46,6 +65,7 @@ func main() {
 
 	// HTTP API
 	r.POST("/api/ai-response/send", apiHandler.ReceiveAIResponse)
+	r.POST("/api/ai-response/stream", apiHandler.ReceiveAIResponseStream)
 	r.POST("/api/user/login", apiHandler.UserLogin)
 	r.GET("/api/health", func(c *gin.Context) {
 		c.Set("service_name", cfg.Server.Name)
@@ -4,20 +4,37 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
 	"github.com/supportbot/supportbot-go/internal/client"
 	"github.com/supportbot/supportbot-go/internal/config"
+	"github.com/supportbot/supportbot-go/internal/handler"
 	"github.com/supportbot/supportbot-go/internal/middleware"
 	"github.com/supportbot/supportbot-go/internal/model"
 	"github.com/supportbot/supportbot-go/internal/service"
+	"github.com/supportbot/supportbot-go/internal/service/knowledge"
 	"github.com/supportbot/supportbot-go/internal/vectorstore"
 	"github.com/supportbot/supportbot-go/pkg/logger"
+	"github.com/supportbot/supportbot-go/pkg/redis"
 	"go.uber.org/zap"
 )
 
+// IngestURLRequest 通过 URL 摄取长文档的请求体
+type IngestURLRequest struct {
+	ID  string `json:"id"`
+	URL string `json:"url" binding:"required"`
+}
+
+// DeleteBySourceRequest 按来源批量删除分片的请求体
+type DeleteBySourceRequest struct {
+	ParentID string `json:"parentId" binding:"required"`
+}
+
 type RAGRequest struct {
 	UserID   int64  `json:"userId"`
 	Question string `json:"question"`
@@ -44,16 +61,51 @@ func main() {
 	zapLogger.Info("knowledge-rag 服务启动中...")
 
 	// 初始化 LLM 客户端
-	llmClient := client.NewDashScopeClient(cfg.DashScope.APIKey, cfg.DashScope.Model, zapLogger)
+	llmClient := client.NewDashScopeClient(cfg.LLM.APIKey, cfg.LLM.Model, zapLogger)
 
 	// 初始化 Embedding 客户端
-	embeddingClient := client.NewEmbeddingClient(cfg.DashScope.APIKey, zapLogger)
+	embeddingClient := client.NewEmbeddingClient(cfg.LLM.APIKey, zapLogger)
 
-	// 初始化向量存储
-	vectorStore := vectorstore.NewMemoryVectorStore(zapLogger)
+	// 初始化向量存储（根据配置选择 memory / bolt / qdrant 后端）
+	vectorStore, err := vectorstore.NewVectorStore(cfg.VectorStore, zapLogger)
+	if err != nil {
+		log.Fatalf("初始化向量存储失败: %v", err)
+	}
 
 	// 初始化知识库服务
-	knowledgeService := service.NewKnowledgeService(embeddingClient, vectorStore, zapLogger)
+	knowledgeService := service.NewKnowledgeService(embeddingClient, vectorStore, cfg.Retrieval.Mode, zapLogger)
+
+	// 按配置接入交叉编码重排阶段（仅在纯向量检索模式下生效）
+	if cfg.Retrieval.Rerank.Enabled {
+		rerankClient := client.NewRerankClient(cfg.LLM.APIKey, cfg.Retrieval.Rerank.Model, zapLogger)
+		knowledgeService.SetReranker(service.NewCachingReranker(service.NewDashScopeReranker(rerankClient), zapLogger))
+	}
+
+	// 初始化长文档切片摄取器（上传/URL 摄取共用）
+	ingestor := knowledge.NewIngestor(embeddingClient, 0, 0, zapLogger)
+	knowledgeService.SetIngestor(ingestor)
+
+	// 配置了 Redis 时接入文档元数据缓存，减少 small-to-big 检索中重复的按 ID 查找，
+	// 并接入对话记忆（摘要 + 滚动窗口）和分片上传的断点状态；未配置则跳过，分别
+	// 回源到 vectorStore、退化为无历史上下文、不支持断点续传
+	var memoryService *service.MemoryService
+	var uploadRedisClient *goredis.Client
+	if cfg.Redis.Host != "" {
+		redisClient, err := redis.NewRedisClient(cfg.Redis)
+		if err != nil {
+			zapLogger.Warn("连接 Redis 失败，跳过文档元数据缓存、对话记忆与分片上传", zap.Error(err))
+		} else {
+			knowledgeService.SetMetadataCache(redisClient)
+			uploadRedisClient = redisClient
+
+			llmProvider, err := client.NewLLMProvider(cfg.LLM, zapLogger)
+			if err != nil {
+				zapLogger.Warn("初始化 LLM Provider 失败，跳过对话记忆", zap.Error(err))
+			} else {
+				memoryService = service.NewMemoryService(redisClient, llmProvider, zapLogger)
+			}
+		}
+	}
 
 	// 加载默认知识库
 	if err := knowledgeService.InitDefaultKnowledge(); err != nil {
@@ -62,6 +114,12 @@ func main() {
 
 	imDemoURL := cfg.Services.IMDemo
 
+	// 仅在配置了 Redis 时才提供分片上传接口，断点状态依赖 uploadRedisClient
+	var uploadHandler *handler.UploadHandler
+	if uploadRedisClient != nil {
+		uploadHandler = handler.NewUploadHandler(knowledgeService, ingestor, uploadRedisClient, cfg.Upload.StagingDir, zapLogger)
+	}
+
 	r := gin.Default()
 	r.Use(middleware.CORS())
 
@@ -77,7 +135,7 @@ func main() {
 			zap.Int64("userId", req.UserID),
 			zap.String("question", req.Question))
 
-		go processRAG(req, llmClient, knowledgeService, imDemoURL, zapLogger)
+		go processRAG(req, llmClient, knowledgeService, memoryService, imDemoURL, zapLogger)
 		c.JSON(200, gin.H{"status": "processing"})
 	})
 
@@ -100,6 +158,107 @@ func main() {
 		})
 	})
 
+	// 上传文件摄取接口（multipart，按文件后缀选择抽取器：PDF、HTML、CSV、JSONL，其余按 Markdown / 纯文本处理）
+	r.POST("/api/knowledge/upload", func(c *gin.Context) {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(400, gin.H{"error": "缺少 file 字段"})
+			return
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(file)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		sourceID := c.PostForm("id")
+		if sourceID == "" {
+			sourceID = uuid.New().String()
+		}
+
+		docs, err := ingestor.IngestFile(sourceID, fileHeader.Filename, data)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := knowledgeService.AddKnowledgeChunks(docs); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"status":   "success",
+			"parentId": sourceID,
+			"chunks":   len(docs),
+		})
+	})
+
+	// 分片/断点续传上传接口：大文件按分片上传，最后一片触发合并与摄取
+	if uploadHandler != nil {
+		r.POST("/api/upload/chunk", uploadHandler.UploadChunk)
+		r.GET("/api/upload/status", uploadHandler.UploadStatus)
+	}
+
+	// URL 摄取接口：抓取正文、切片、向量化并写入知识库
+	r.POST("/api/knowledge/ingest-url", func(c *gin.Context) {
+		var req IngestURLRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		sourceID := req.ID
+		if sourceID == "" {
+			sourceID = uuid.New().String()
+		}
+
+		docs, err := ingestor.IngestURL(sourceID, req.URL)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := knowledgeService.AddKnowledgeChunks(docs); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"status":   "success",
+			"parentId": sourceID,
+			"chunks":   len(docs),
+		})
+	})
+
+	// 按来源批量删除分片接口
+	r.DELETE("/api/knowledge/by-source", func(c *gin.Context) {
+		var req DeleteBySourceRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		count, err := knowledgeService.DeleteBySource(req.ParentID)
+		if err != nil {
+			c.JSON(404, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"status":  "success",
+			"deleted": count,
+		})
+	})
+
 	// 查询知识接口
 	r.GET("/api/knowledge/search", func(c *gin.Context) {
 		query := c.Query("q")
@@ -108,12 +267,24 @@ func main() {
 			return
 		}
 
-		results, err := knowledgeService.SearchKnowledge(query, 5, 0.7)
+		var (
+			results []vectorstore.SearchResult
+			err     error
+		)
+		if category := c.Query("category"); category != "" {
+			results, err = knowledgeService.SearchKnowledgeWithFilter(query, 5, 0.7, vectorstore.MetadataFilter{"category": category})
+		} else {
+			results, err = knowledgeService.SearchKnowledge(query, 5, 0.7)
+		}
 		if err != nil {
 			c.JSON(500, gin.H{"error": err.Error()})
 			return
 		}
 
+		if c.Query("groupByParent") == "true" {
+			results = service.GroupByParent(results)
+		}
+
 		c.JSON(200, gin.H{
 			"results": results,
 			"count":   len(results),
@@ -143,7 +314,7 @@ func main() {
 }
 
 func processRAG(req RAGRequest, llmClient *client.DashScopeClient,
-	knowledgeService *service.KnowledgeService, imDemoURL string, logger *zap.Logger) {
+	knowledgeService *service.KnowledgeService, memoryService *service.MemoryService, imDemoURL string, logger *zap.Logger) {
 
 	logger.Info("开始 RAG 检索", zap.String("question", req.Question))
 
@@ -155,10 +326,11 @@ func processRAG(req RAGRequest, llmClient *client.DashScopeClient,
 		return
 	}
 
-	// 2. 构建上下文
-	knowledgeContext := knowledgeService.BuildContext(results)
+	// 2. 构建上下文（按预算裁剪、去重相似片段，附带引用标记）
+	knowledgeContext, citations := knowledgeService.BuildContext(results, service.BudgetOptions{})
 	logger.Info("检索完成",
 		zap.Int("results", len(results)),
+		zap.Int("citations", len(citations)),
 		zap.Float64("top_score", getTopScore(results)))
 
 	// 3. 使用 LLM 生成回答
@@ -173,7 +345,20 @@ func processRAG(req RAGRequest, llmClient *client.DashScopeClient,
 4. 如果有多个相关信息，优先使用相似度最高的
 5. 适当使用emoji增加亲和力`
 
+	// 取对话记忆（摘要 + 最近轮次），让回答能延续同一用户此前的上下文
+	var historyContext string
+	if memoryService != nil {
+		var err error
+		historyContext, err = memoryService.BuildContext(req.UserID, 0)
+		if err != nil {
+			logger.Warn("读取对话记忆失败，本次回答将不带历史上下文", zap.Int64("userId", req.UserID), zap.Error(err))
+		}
+	}
+
 	prompt := fmt.Sprintf("%s\n\n用户问题：%s\n\n请基于上述知识回答用户问题。", knowledgeContext, req.Question)
+	if historyContext != "" {
+		prompt = fmt.Sprintf("%s\n\n%s", historyContext, prompt)
+	}
 
 	response, err := llmClient.SimpleChat(systemPrompt, prompt)
 	if err != nil {
@@ -181,6 +366,13 @@ func processRAG(req RAGRequest, llmClient *client.DashScopeClient,
 		response = "抱歉，暂时无法处理您的问题，请稍后重试。"
 	}
 
+	// 用户问题已经在 question-classifier 路由前记过，这里只补记回复那一半
+	if memoryService != nil {
+		if err := memoryService.AppendTurn(req.UserID, "assistant", response); err != nil {
+			logger.Error("记录对话记忆失败", zap.Int64("userId", req.UserID), zap.Error(err))
+		}
+	}
+
 	sendToIM(req.UserID, response, imDemoURL, logger)
 }
 
@@ -37,8 +37,14 @@ func main() {
 		zapLogger.Fatal("连接 Redis 失败", zap.Error(err))
 	}
 
-	// 初始化 LLM 客户端
-	llmClient := client.NewDashScopeClient(cfg.DashScope.APIKey, cfg.DashScope.Model, zapLogger)
+	// 初始化 LLM Provider（按 cfg.LLM.Provider 选择 dashscope/openai/ollama/azure-openai）
+	llmProvider, err := client.NewLLMProvider(cfg.LLM, zapLogger)
+	if err != nil {
+		zapLogger.Fatal("初始化 LLM Provider 失败", zap.Error(err))
+	}
+
+	// 初始化 Embedding 客户端（用于语义分类，目前固定走 DashScope 的 text-embedding-v2）
+	embeddingClient := client.NewEmbeddingClient(cfg.LLM.APIKey, zapLogger)
 
 	// 分类配置（从配置文件读取）
 	categories := map[string]service.CategoryInfo{
@@ -77,12 +83,35 @@ func main() {
 
 请只返回分类名称。`
 
+	// 初始化对话记忆服务（摘要 + 滚动窗口），分类消歧与下游 Agent 共用同一份记忆
+	memoryService := service.NewMemoryService(redisClient, llmProvider, zapLogger)
+
 	// 初始化服务
-	classifierService := service.NewClassifierService(llmClient, redisClient, categories, systemPrompt, zapLogger)
+	classifierService := service.NewClassifierService(llmProvider, embeddingClient, redisClient, memoryService, categories, systemPrompt, cfg.Classify.FallbackThreshold, zapLogger)
 
 	// 初始化处理器
 	classifierHandler := handler.NewClassifierHandler(classifierService, zapLogger)
 
+	// 监听配置文件变更：services.* 地址变了就重建路由表，不用重启进程
+	stopWatch := make(chan struct{})
+	defer close(stopWatch)
+	if reloaded, err := config.Watch("configs/question-classifier.yaml", zapLogger, stopWatch); err != nil {
+		zapLogger.Warn("启动配置热加载失败，配置变更需要重启进程才能生效", zap.Error(err))
+	} else {
+		go func() {
+			for newCfg := range reloaded {
+				agentURLs := map[string]string{
+					"product.inquiry": newCfg.Services.Assistant + "/api/process",
+					"order.status":    newCfg.Services.Assistant + "/api/process",
+					"knowledge.query": newCfg.Services.KnowledgeRAG + "/api/rag",
+					"general-chat":    newCfg.Services.GeneralChat + "/api/chat",
+				}
+				classifierService.UpdateAgentURLs(agentURLs)
+				zapLogger.Info("已按新配置刷新 Agent 路由表")
+			}
+		}()
+	}
+
 	// 初始化路由
 	r := gin.Default()
 	r.Use(middleware.CORS())
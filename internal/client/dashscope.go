@@ -1,12 +1,16 @@
 package client
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
+	"github.com/supportbot/supportbot-go/internal/config"
+	"github.com/supportbot/supportbot-go/internal/tools"
 	"go.uber.org/zap"
 )
 
@@ -30,14 +34,17 @@ func NewDashScopeClient(apiKey, model string, logger *zap.Logger) *DashScopeClie
 
 // Message 消息
 type Message struct {
-	Role    string `json:"role"`    // system, user, assistant
-	Content string `json:"content"`
+	Role       string           `json:"role"` // system, user, assistant, tool
+	Content    string           `json:"content"`
+	ToolCalls  []tools.ToolCall `json:"tool_calls,omitempty"`   // role=assistant 时，本轮模型请求的工具调用，回放给下一轮请求
+	ToolCallID string           `json:"tool_call_id,omitempty"` // role=tool 时，对应触发该结果的工具调用 ID
+	Name       string           `json:"name,omitempty"`         // role=tool 时，工具名称
 }
 
 // ChatRequest 聊天请求
 type ChatRequest struct {
-	Model      string    `json:"model"`
-	Input      Input     `json:"input"`
+	Model      string     `json:"model"`
+	Input      Input      `json:"input"`
 	Parameters Parameters `json:"parameters,omitempty"`
 }
 
@@ -48,9 +55,10 @@ type Input struct {
 
 // Parameters 参数
 type Parameters struct {
-	Temperature float64 `json:"temperature,omitempty"`
-	TopP        float64 `json:"top_p,omitempty"`
-	MaxTokens   int     `json:"max_tokens,omitempty"`
+	Temperature       float64 `json:"temperature,omitempty"`
+	TopP              float64 `json:"top_p,omitempty"`
+	MaxTokens         int     `json:"max_tokens,omitempty"`
+	IncrementalOutput bool    `json:"incremental_output,omitempty"` // SSE 流式模式下是否只返回本次增量文本
 }
 
 // ChatResponse 聊天响应
@@ -117,6 +125,85 @@ func (c *DashScopeClient) Chat(messages []Message) (string, error) {
 	return chatResp.Output.Text, nil
 }
 
+// ChatStream 以 SSE 方式调用通义千问聊天接口，边生成边通过 handler 回调增量文本，
+// 避免调用方像 Chat 那样阻塞等待完整回复（通常需要 5-15s）。handler 返回错误会
+// 中断流式读取并将该错误向上返回。
+func (c *DashScopeClient) ChatStream(messages []Message, handler func(delta string) error) error {
+	url := "https://dashscope.aliyuncs.com/api/v1/services/aigc/text-generation/generation"
+
+	reqBody := ChatRequest{
+		Model: c.model,
+		Input: Input{
+			Messages: messages,
+		},
+		Parameters: Parameters{
+			Temperature:       0.7,
+			MaxTokens:         2000,
+			IncrementalOutput: true,
+		},
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("X-DashScope-SSE", "enable")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API 返回错误: %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" {
+			continue
+		}
+
+		var chunk ChatResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("解析流式响应失败: %w", err)
+		}
+
+		if chunk.Output.Text != "" {
+			if err := handler(chunk.Output.Text); err != nil {
+				return err
+			}
+		}
+		if chunk.Output.FinishReason != "" && chunk.Output.FinishReason != "null" {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取流式响应失败: %w", err)
+	}
+
+	return nil
+}
+
 // SimpleChat 简单聊天（单轮对话）
 func (c *DashScopeClient) SimpleChat(systemPrompt, userMessage string) (string, error) {
 	messages := []Message{
@@ -126,3 +213,122 @@ func (c *DashScopeClient) SimpleChat(systemPrompt, userMessage string) (string,
 	return c.Chat(messages)
 }
 
+// ToolChatParameters 带工具定义的聊天参数。result_format 必须为 "message"，
+// 这样 DashScope 才会按 OpenAI 兼容的 choices[].message 结构返回 tool_calls。
+type ToolChatParameters struct {
+	Temperature  float64                  `json:"temperature,omitempty"`
+	MaxTokens    int                      `json:"max_tokens,omitempty"`
+	ResultFormat string                   `json:"result_format"`
+	Tools        []map[string]interface{} `json:"tools,omitempty"`
+}
+
+// ToolChatRequest 带工具定义的聊天请求
+type ToolChatRequest struct {
+	Model      string             `json:"model"`
+	Input      Input              `json:"input"`
+	Parameters ToolChatParameters `json:"parameters"`
+}
+
+// ToolChatResponse result_format=message 下的响应结构，包含可能的 tool_calls
+type ToolChatResponse struct {
+	Output struct {
+		Choices []struct {
+			FinishReason string `json:"finish_reason"`
+			Message      struct {
+				Role      string           `json:"role"`
+				Content   string           `json:"content"`
+				ToolCalls []tools.ToolCall `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+	} `json:"output"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	RequestID string `json:"request_id"`
+}
+
+// ChatWithTools 调用支持函数调用的通义千问聊天接口。toolDefs 使用
+// tools.Registry.AsFunctionDefs() 生成的 OpenAI 兼容 schema。返回模型的文本回复
+// （工具调用轮次中通常为空）以及模型请求执行的工具调用列表。
+func (c *DashScopeClient) ChatWithTools(systemPrompt string, messages []Message, toolDefs []map[string]interface{}) (string, []tools.ToolCall, error) {
+	url := "https://dashscope.aliyuncs.com/api/v1/services/aigc/text-generation/generation"
+
+	allMessages := append([]Message{{Role: "system", Content: systemPrompt}}, messages...)
+
+	reqBody := ToolChatRequest{
+		Model: c.model,
+		Input: Input{Messages: allMessages},
+		Parameters: ToolChatParameters{
+			Temperature:  0.7,
+			MaxTokens:    2000,
+			ResultFormat: "message",
+			Tools:        toolDefs,
+		},
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	if resp.StatusCode != 200 {
+		return "", nil, fmt.Errorf("API 返回错误: %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	var chatResp ToolChatResponse
+	if err := json.Unmarshal(body, &chatResp); err != nil {
+		return "", nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+
+	if len(chatResp.Output.Choices) == 0 {
+		return "", nil, fmt.Errorf("响应中不包含任何 choice")
+	}
+
+	choice := chatResp.Output.Choices[0]
+	return choice.Message.Content, choice.Message.ToolCalls, nil
+}
+
+// dashScopeProvider 把 DashScopeClient 适配成 LLMProvider，是 NewLLMProvider
+// 未配置 provider 时使用的默认驱动
+type dashScopeProvider struct {
+	chat *DashScopeClient
+}
+
+// Chat 实现 LLMProvider
+func (p *dashScopeProvider) Chat(messages []Message) (string, error) {
+	return p.chat.Chat(messages)
+}
+
+// ChatStream 实现 LLMProvider，把 ChatStream 的 delta 文本包装成 Delta
+func (p *dashScopeProvider) ChatStream(messages []Message, handler func(Delta) error) error {
+	return p.chat.ChatStream(messages, func(delta string) error {
+		return handler(Delta{Content: delta})
+	})
+}
+
+func init() {
+	RegisterProvider("dashscope", func(cfg config.LLMConfig, logger *zap.Logger) (LLMProvider, error) {
+		return &dashScopeProvider{
+			chat: NewDashScopeClient(cfg.APIKey, cfg.Model, logger),
+		}, nil
+	})
+}
@@ -0,0 +1,222 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/supportbot/supportbot-go/internal/config"
+	"go.uber.org/zap"
+)
+
+// defaultOpenAIBaseURL OpenAI 官方 API 地址，cfg.BaseURL 留空且 provider 为
+// openai 时使用
+const defaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+// defaultOllamaBaseURL Ollama 本地 OpenAI 兼容层默认地址
+const defaultOllamaBaseURL = "http://localhost:11434/v1"
+
+// OpenAIClient 面向 OpenAI Chat Completions / Embeddings 接口的客户端，同样
+// 适用于任何遵循该协议的 OpenAI 兼容后端（vLLM、LM Studio、DeepSeek、Ollama、
+// Azure OpenAI 等），只需把 baseURL 指向对应服务即可
+type OpenAIClient struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+	logger     *zap.Logger
+}
+
+// NewOpenAIClient 创建 OpenAI 兼容客户端，baseURL 末尾的 "/" 会被去掉
+func NewOpenAIClient(baseURL, apiKey, model string, logger *zap.Logger) *OpenAIClient {
+	return &OpenAIClient{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		apiKey:     apiKey,
+		model:      model,
+		httpClient: &http.Client{},
+		logger:     logger,
+	}
+}
+
+// openAIChatRequest 请求体，stream 为 true 时走 SSE
+type openAIChatRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Stream   bool      `json:"stream,omitempty"`
+}
+
+// openAIChatResponse 非流式响应
+type openAIChatResponse struct {
+	Choices []struct {
+		Message      Message `json:"message"`
+		FinishReason string  `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// openAIChatStreamChunk 流式响应的单个 SSE data 帧
+type openAIChatStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// newRequest 构造一个带 Content-Type/Authorization 头的 POST 请求
+func (c *OpenAIClient) newRequest(path string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest("POST", c.baseURL+path, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	return req, nil
+}
+
+// Chat 调用 /chat/completions 接口，阻塞等待完整回复
+func (c *OpenAIClient) Chat(messages []Message) (string, error) {
+	jsonData, err := json.Marshal(openAIChatRequest{Model: c.model, Messages: messages})
+	if err != nil {
+		return "", fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	req, err := c.newRequest("/chat/completions", jsonData)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("API 返回错误: %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	var chatResp openAIChatResponse
+	if err := json.Unmarshal(body, &chatResp); err != nil {
+		return "", fmt.Errorf("解析响应失败: %w", err)
+	}
+	if len(chatResp.Choices) == 0 {
+		return "", fmt.Errorf("响应中不包含任何 choice")
+	}
+
+	return chatResp.Choices[0].Message.Content, nil
+}
+
+// ChatStream 以 SSE 方式调用 /chat/completions 接口，边生成边通过 handler 回调
+// 增量文本；handler 返回错误会中断流式读取并将该错误向上返回
+func (c *OpenAIClient) ChatStream(messages []Message, handler func(delta string) error) error {
+	jsonData, err := json.Marshal(openAIChatRequest{Model: c.model, Messages: messages, Stream: true})
+	if err != nil {
+		return fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	req, err := c.newRequest("/chat/completions", jsonData)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API 返回错误: %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" {
+			continue
+		}
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openAIChatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("解析流式响应失败: %w", err)
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		if delta := chunk.Choices[0].Delta.Content; delta != "" {
+			if err := handler(delta); err != nil {
+				return err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取流式响应失败: %w", err)
+	}
+
+	return nil
+}
+
+// 注：OpenAIClient 不提供 Embed。knowledge-rag/question-classifier 的向量化
+// 固定通过 client.NewEmbeddingClient 走 DashScope 的 text-embedding-v2，与这里
+// 配置的对话 Provider（openai/ollama/azure-openai 等）无关；聊天模型（如
+// gpt-4）本身也不是合法的 embeddings 模型，强行对接只会在调用时报错。
+
+// openAIProvider 把 OpenAIClient 适配成 LLMProvider
+type openAIProvider struct {
+	client *OpenAIClient
+}
+
+func (p *openAIProvider) Chat(messages []Message) (string, error) {
+	return p.client.Chat(messages)
+}
+
+func (p *openAIProvider) ChatStream(messages []Message, handler func(Delta) error) error {
+	return p.client.ChatStream(messages, func(delta string) error {
+		return handler(Delta{Content: delta})
+	})
+}
+
+// newOpenAICompatProvider 用给定的默认地址构造 openAIProvider，cfg.BaseURL 留空
+// 时回退到 defaultBaseURL，供 openai/ollama/azure-openai 等复用同一套驱动
+func newOpenAICompatProvider(defaultBaseURL string) ProviderFactory {
+	return func(cfg config.LLMConfig, logger *zap.Logger) (LLMProvider, error) {
+		baseURL := cfg.BaseURL
+		if baseURL == "" {
+			baseURL = defaultBaseURL
+		}
+		return &openAIProvider{client: NewOpenAIClient(baseURL, cfg.APIKey, cfg.Model, logger)}, nil
+	}
+}
+
+func init() {
+	RegisterProvider("openai", newOpenAICompatProvider(defaultOpenAIBaseURL))
+	// ollama 的 /v1 兼容层和 Azure OpenAI 的 OpenAI 兼容端点都遵循同一套协议，
+	// 复用 openai 驱动，仅默认地址不同；Azure 必须显式配置 baseUrl（含部署名/
+	// api-version），没有通用默认值
+	RegisterProvider("ollama", newOpenAICompatProvider(defaultOllamaBaseURL))
+	RegisterProvider("azure-openai", newOpenAICompatProvider(""))
+}
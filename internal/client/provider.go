@@ -0,0 +1,71 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/supportbot/supportbot-go/internal/config"
+	"go.uber.org/zap"
+)
+
+// Delta 流式对话中的一帧增量输出
+type Delta struct {
+	Content string // 本次增量文本
+	Done    bool   // 内置的 DashScope/OpenAI 驱动不设置该字段；ChatStream 返回即表示流已结束
+}
+
+// LLMProvider 对话模型的抽象，屏蔽 DashScope、OpenAI 兼容接口（vLLM、
+// LM Studio、DeepSeek 等）、Ollama、Azure OpenAI 等具体后端的差异，使
+// ClassifierService、assistant、general-chat 可以通过 config.LLMConfig.Provider
+// 切换供应商而无需改动调用方代码。向量化不在这个抽象里：知识库检索和问题分类
+// 目前固定通过 client.NewEmbeddingClient 走 DashScope 的 text-embedding-v2，
+// 与这里配置的对话 Provider 无关。
+type LLMProvider interface {
+	// Chat 单轮/多轮对话，阻塞直到返回完整回复
+	Chat(messages []Message) (string, error)
+	// ChatStream 以增量帧形式返回回复，handler 对每个 Delta 调用一次
+	ChatStream(messages []Message, handler func(Delta) error) error
+}
+
+// SimpleChat 单轮对话的便捷封装：拼出 system+user 两条消息后调用 provider.Chat
+func SimpleChat(provider LLMProvider, systemPrompt, userMessage string) (string, error) {
+	return provider.Chat([]Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: userMessage},
+	})
+}
+
+// ProviderFactory 根据配置创建一个 LLMProvider 实例
+type ProviderFactory func(cfg config.LLMConfig, logger *zap.Logger) (LLMProvider, error)
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]ProviderFactory)
+)
+
+// RegisterProvider 注册一个 LLM 供应商驱动，name 对应 config.LLMConfig.Provider
+// 中使用的名字。内置的 dashscope/openai/ollama/azure-openai 驱动在各自文件的
+// init() 中完成注册；接入新供应商只需在引入包时调用 RegisterProvider，无需
+// 修改 NewLLMProvider 或调用方。同名重复注册会覆盖旧驱动。
+func RegisterProvider(name string, factory ProviderFactory) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[name] = factory
+}
+
+// NewLLMProvider 根据配置创建 LLMProvider 实例，未配置 provider 时默认使用 dashscope
+func NewLLMProvider(cfg config.LLMConfig, logger *zap.Logger) (LLMProvider, error) {
+	name := cfg.Provider
+	if name == "" {
+		name = "dashscope"
+	}
+
+	providersMu.RLock()
+	factory, ok := providers[name]
+	providersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("不支持的 LLM 供应商: %s", name)
+	}
+
+	return factory(cfg, logger)
+}
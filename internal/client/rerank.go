@@ -0,0 +1,117 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"go.uber.org/zap"
+)
+
+// RerankClient 通义千问 gte-rerank 交叉编码器客户端，用于对初筛候选做二次精排
+type RerankClient struct {
+	apiKey string
+	model  string
+	logger *zap.Logger
+	client *http.Client
+}
+
+// rerankRequest 请求结构
+type rerankRequest struct {
+	Model string            `json:"model"`
+	Input rerankRequestBody `json:"input"`
+}
+
+type rerankRequestBody struct {
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+}
+
+// rerankResponse 响应结构
+type rerankResponse struct {
+	Output struct {
+		Results []struct {
+			Index          int     `json:"index"`
+			RelevanceScore float64 `json:"relevance_score"`
+		} `json:"results"`
+	} `json:"output"`
+	RequestID string `json:"request_id"`
+}
+
+// RerankResult 单条重排结果，Index 对应传入 documents 的下标
+type RerankResult struct {
+	Index int
+	Score float64
+}
+
+// NewRerankClient 创建 gte-rerank 客户端，model 留空时默认使用 "gte-rerank"
+func NewRerankClient(apiKey, model string, logger *zap.Logger) *RerankClient {
+	if model == "" {
+		model = "gte-rerank"
+	}
+	return &RerankClient{
+		apiKey: apiKey,
+		model:  model,
+		logger: logger,
+		client: &http.Client{},
+	}
+}
+
+// Rerank 对 query 与 documents 做交叉编码打分，返回按 Score 降序排列的结果
+func (c *RerankClient) Rerank(query string, documents []string) ([]RerankResult, error) {
+	c.logger.Info("交叉编码重排", zap.String("query", query), zap.Int("candidates", len(documents)))
+
+	reqBody := rerankRequest{
+		Model: c.model,
+		Input: rerankRequestBody{
+			Query:     query,
+			Documents: documents,
+		},
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", "https://dashscope.aliyuncs.com/api/v1/services/rerank/text-rerank/text-rerank", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API 返回错误 %d: %s", resp.StatusCode, string(body))
+	}
+
+	var rerankResp rerankResponse
+	if err := json.Unmarshal(body, &rerankResp); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+
+	results := make([]RerankResult, len(rerankResp.Output.Results))
+	for i, r := range rerankResp.Output.Results {
+		results[i] = RerankResult{Index: r.Index, Score: r.RelevanceScore}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+
+	c.logger.Info("交叉编码重排完成", zap.Int("results", len(results)))
+
+	return results, nil
+}
@@ -3,68 +3,326 @@ package config
 import (
 	"fmt"
 	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
 	"gopkg.in/yaml.v3"
 )
 
 // Config 应用配置
 type Config struct {
-	Server   ServerConfig   `yaml:"server"`
-	Redis    RedisConfig    `yaml:"redis"`
-	DashScope DashScopeConfig `yaml:"dashscope"`
-	Services ServicesConfig `yaml:"services"`
-	Log      LogConfig      `yaml:"log"`
+	Server      ServerConfig      `yaml:"server"`
+	Redis       RedisConfig       `yaml:"redis"`
+	LLM         LLMConfig         `yaml:"llm"`
+	Services    ServicesConfig    `yaml:"services"`
+	Log         LogConfig         `yaml:"log"`
+	VectorStore VectorStoreConfig `yaml:"vectorStore"`
+	Retrieval   RetrievalConfig   `yaml:"retrieval"`
+	Classify    ClassifyConfig    `yaml:"classify"`
+	Upload      UploadConfig      `yaml:"upload"`
 }
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-	Port int    `yaml:"port"`
-	Name string `yaml:"name"`
+	Port int    `yaml:"port" env:"SUPPORTBOT_SERVER_PORT" validate:"required,min=1,max=65535"`
+	Name string `yaml:"name" env:"SUPPORTBOT_SERVER_NAME"`
 }
 
 // RedisConfig Redis 配置
 type RedisConfig struct {
-	Host     string `yaml:"host"`
-	Port     int    `yaml:"port"`
-	Password string `yaml:"password"`
-	DB       int    `yaml:"db"`
+	Host     string `yaml:"host" env:"SUPPORTBOT_REDIS_HOST"`
+	Port     int    `yaml:"port" env:"SUPPORTBOT_REDIS_PORT" validate:"min=0,max=65535"`
+	Password string `yaml:"password" env:"SUPPORTBOT_REDIS_PASSWORD" secret:"true"`
+	DB       int    `yaml:"db" env:"SUPPORTBOT_REDIS_DB"`
 }
 
-// DashScopeConfig 通义千问配置
-type DashScopeConfig struct {
-	APIKey string `yaml:"apiKey"`
-	Model  string `yaml:"model"`
+// LLMConfig 对话/向量化模型配置。Provider 决定 client.NewLLMProvider 选用哪个
+// 驱动，默认 dashscope；切到 openai/ollama/azure-openai 等 OpenAI 兼容后端时
+// 通过 BaseURL 指向对应的服务地址（vLLM、LM Studio、DeepSeek 等同理）。
+type LLMConfig struct {
+	Provider string `yaml:"provider" env:"SUPPORTBOT_LLM_PROVIDER"` // dashscope, openai, ollama, azure-openai；默认 dashscope
+	// APIKey 除 ollama（本地部署，通常无需鉴权）外的 provider 都必填
+	APIKey  string `yaml:"apiKey" env:"SUPPORTBOT_LLM_APIKEY" validate:"required_unless=Provider ollama" secret:"true"`
+	Model   string `yaml:"model" env:"SUPPORTBOT_LLM_MODEL"`
+	BaseURL string `yaml:"baseUrl" env:"SUPPORTBOT_LLM_BASEURL"` // 仅 OpenAI 兼容驱动使用，留空时各驱动使用各自默认地址
 }
 
 // ServicesConfig 服务地址配置
 type ServicesConfig struct {
-	IMDemo              string `yaml:"imDemo"`
-	QuestionClassifier  string `yaml:"questionClassifier"`
-	Assistant           string `yaml:"assistant"`
-	GeneralChat         string `yaml:"generalChat"`
-	KnowledgeRAG        string `yaml:"knowledgeRag"`
-	ProductService      string `yaml:"productService"`
-	TradeService        string `yaml:"tradeService"`
-	WorkOrderService    string `yaml:"workOrderService"`
+	IMDemo             string `yaml:"imDemo" env:"SUPPORTBOT_SERVICES_IMDEMO"`
+	QuestionClassifier string `yaml:"questionClassifier" env:"SUPPORTBOT_SERVICES_QUESTION_CLASSIFIER"`
+	Assistant          string `yaml:"assistant" env:"SUPPORTBOT_SERVICES_ASSISTANT"`
+	GeneralChat        string `yaml:"generalChat" env:"SUPPORTBOT_SERVICES_GENERAL_CHAT"`
+	KnowledgeRAG       string `yaml:"knowledgeRag" env:"SUPPORTBOT_SERVICES_KNOWLEDGE_RAG"`
+	ProductService     string `yaml:"productService" env:"SUPPORTBOT_SERVICES_PRODUCT"`
+	TradeService       string `yaml:"tradeService" env:"SUPPORTBOT_SERVICES_TRADE"`
+	WorkOrderService   string `yaml:"workOrderService" env:"SUPPORTBOT_SERVICES_WORK_ORDER"`
 }
 
 // LogConfig 日志配置
 type LogConfig struct {
-	Level string `yaml:"level"` // debug, info, warn, error
+	Level string `yaml:"level" env:"SUPPORTBOT_LOG_LEVEL" validate:"omitempty,oneof=debug info warn error"` // debug, info, warn, error
 }
 
-// LoadConfig 加载配置文件
+// VectorStoreConfig 向量存储配置，决定 knowledge-rag 知识库数据落在哪个后端
+type VectorStoreConfig struct {
+	Backend string            `yaml:"backend"` // memory, bolt, hnsw, qdrant；默认为 memory
+	Bolt    BoltStoreConfig   `yaml:"bolt"`
+	HNSW    HNSWStoreConfig   `yaml:"hnsw"`
+	Qdrant  QdrantStoreConfig `yaml:"qdrant"`
+}
+
+// HNSWStoreConfig HNSW 近似最近邻索引配置
+type HNSWStoreConfig struct {
+	M              int    `yaml:"m"`              // 每节点每层邻居数，默认 16
+	EfConstruction int    `yaml:"efConstruction"` // 建图候选集大小，默认 200
+	EfSearch       int    `yaml:"efSearch"`       // 查询候选集大小，默认 64
+	SnapshotPath   string `yaml:"snapshotPath"`   // 图+向量快照落盘路径，留空则不持久化
+}
+
+// BoltStoreConfig BoltDB 持久化向量存储配置
+type BoltStoreConfig struct {
+	Path string `yaml:"path"` // 数据文件路径，例如 data/knowledge.db
+}
+
+// QdrantStoreConfig Qdrant 向量数据库配置
+type QdrantStoreConfig struct {
+	BaseURL    string `yaml:"baseUrl" env:"SUPPORTBOT_QDRANT_BASEURL"` // 例如 http://localhost:6333
+	Collection string `yaml:"collection"`                              // 集合名称
+}
+
+// RetrievalConfig 知识库检索策略配置
+type RetrievalConfig struct {
+	Mode   string       `yaml:"mode"` // vector, bm25, hybrid；默认 vector
+	Rerank RerankConfig `yaml:"rerank"`
+}
+
+// RerankConfig 交叉编码重排阶段配置，仅在纯向量检索模式下生效
+type RerankConfig struct {
+	Enabled bool   `yaml:"enabled"` // 是否在向量检索后接入交叉编码重排，默认 false
+	Model   string `yaml:"model"`   // 交叉编码模型名，留空默认使用 gte-rerank
+}
+
+// ClassifyConfig 问题分类服务的 BM25+向量双路检索配置
+type ClassifyConfig struct {
+	FallbackThreshold float64 `yaml:"fallbackThreshold"` // 融合分低于该阈值直接回退到 general-chat，不调用 LLM；留空（<=0）使用 service 包内的默认值
+}
+
+// UploadConfig 分片上传的暂存配置
+type UploadConfig struct {
+	StagingDir string `yaml:"stagingDir" env:"SUPPORTBOT_UPLOAD_STAGING_DIR"` // 分片暂存目录，留空默认 data/uploads
+}
+
+// envInterpolation 匹配 YAML 原文中的 ${ENV_VAR} 占位符
+var envInterpolation = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+var validate = validator.New()
+
+// LoadConfig 加载配置文件：插值 ${ENV_VAR}，解析 YAML，再用 env 标签的环境变量
+// 覆盖对应字段（环境变量优先级最高），最后做结构体校验，任何一步出错都会中止启动。
 func LoadConfig(path string) (*Config, error) {
+	cfg, err := readConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Validate(cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// readConfig 读取并解析配置文件，应用 ${ENV_VAR} 插值与 env 标签覆盖，但不做校验；
+// 供 LoadConfig 和 Watch 复用。
+func readConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("读取配置文件失败: %w", err)
 	}
 
+	data = envInterpolation.ReplaceAllFunc(data, func(match []byte) []byte {
+		name := envInterpolation.FindSubmatch(match)[1]
+		if v, ok := os.LookupEnv(string(name)); ok {
+			return []byte(v)
+		}
+		return match
+	})
+
 	var cfg Config
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("解析配置文件失败: %w", err)
 	}
 
+	applyEnvOverrides(reflect.ValueOf(&cfg).Elem())
+
 	return &cfg, nil
 }
 
+// applyEnvOverrides 递归遍历配置结构体，对每个带 env 标签的字段，如果对应的环境
+// 变量已设置，则用它覆盖 YAML 中读到的值；环境变量始终优先于配置文件。
+func applyEnvOverrides(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fv := v.Field(i)
+
+		if fv.Kind() == reflect.Struct {
+			applyEnvOverrides(fv)
+			continue
+		}
+
+		envKey := field.Tag.Get("env")
+		if envKey == "" {
+			continue
+		}
+		raw, ok := os.LookupEnv(envKey)
+		if !ok {
+			continue
+		}
+
+		switch fv.Kind() {
+		case reflect.String:
+			fv.SetString(raw)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+				fv.SetInt(n)
+			}
+		case reflect.Float32, reflect.Float64:
+			if f, err := strconv.ParseFloat(raw, 64); err == nil {
+				fv.SetFloat(f)
+			}
+		case reflect.Bool:
+			if b, err := strconv.ParseBool(raw); err == nil {
+				fv.SetBool(b)
+			}
+		}
+	}
+}
+
+// Validate 对配置做结构体标签校验（validate:"..."），把所有校验失败项聚合成一条
+// 错误返回，避免每次只报第一个字段导致反复改配置、反复重启才能发现下一个问题。
+func Validate(cfg *Config) error {
+	if err := validate.Struct(cfg); err != nil {
+		verrs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			return fmt.Errorf("配置校验失败: %w", err)
+		}
+
+		msgs := make([]string, 0, len(verrs))
+		for _, fe := range verrs {
+			msgs = append(msgs, fmt.Sprintf("%s 校验失败（规则: %s）", fe.Namespace(), fe.Tag()))
+		}
+		return fmt.Errorf("配置校验失败: %s", strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// String 实现 fmt.Stringer，用于日志打印配置时脱敏，带 secret:"true" 标签的字段
+// （如 LLM.APIKey、Redis.Password）一律替换为 "***"，避免密钥随日志泄露。
+func (c Config) String() string {
+	redacted := redactValue(reflect.ValueOf(c))
+	data, err := yaml.Marshal(redacted)
+	if err != nil {
+		return fmt.Sprintf("<config 序列化失败: %v>", err)
+	}
+	return string(data)
+}
+
+// redactValue 递归地把结构体拷贝为 map[string]interface{}，secret 字段替换为 "***"
+func redactValue(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		out := make(map[string]interface{}, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := field.Tag.Get("yaml")
+			if name == "" {
+				name = field.Name
+			}
+			if field.Tag.Get("secret") == "true" {
+				out[name] = "***"
+				continue
+			}
+			out[name] = redactValue(v.Field(i))
+		}
+		return out
+	default:
+		return v.Interface()
+	}
+}
+
+// Watch 监听配置文件变更，每次写入都会重新读取、应用 env 覆盖并校验，校验通过后
+// 推送到返回的 channel；校验失败的变更会被丢弃并记录日志，不会推送半成品配置，
+// 调用方（日志级别、Agent 地址、分类 map 等订阅者）始终只会收到一份合法配置。
+// 传入的 stop channel 关闭时监听协程退出，返回的 channel 随之关闭。
+func Watch(path string, logger *zap.Logger, stop <-chan struct{}) (<-chan *Config, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("创建配置文件监听器失败: %w", err)
+	}
+	if err := watcher.Add(path); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("监听配置文件失败: %w", err)
+	}
+
+	out := make(chan *Config)
+
+	go func() {
+		defer watcher.Close()
+		defer close(out)
+
+		// 编辑器保存通常会连续触发多个事件（写入+重命名等），做一个小的去抖窗口
+		var debounce *time.Timer
+		reload := make(chan struct{}, 1)
+
+		for {
+			select {
+			case <-stop:
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+				if debounce != nil {
+					debounce.Stop()
+				}
+				debounce = time.AfterFunc(200*time.Millisecond, func() {
+					select {
+					case reload <- struct{}{}:
+					default:
+					}
+				})
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Warn("配置文件监听出错", zap.Error(err))
+			case <-reload:
+				cfg, err := readConfig(path)
+				if err != nil {
+					logger.Warn("重新加载配置文件失败，保留旧配置", zap.Error(err))
+					continue
+				}
+				if err := Validate(cfg); err != nil {
+					logger.Warn("新配置未通过校验，保留旧配置", zap.Error(err))
+					continue
+				}
+				logger.Info("配置文件已变更，推送新配置")
+				out <- cfg
+			}
+		}
+	}()
+
+	return out, nil
+}
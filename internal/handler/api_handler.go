@@ -60,12 +60,46 @@ func (h *APIHandler) ReceiveAIResponse(c *gin.Context) {
 	c.JSON(200, gin.H{"success": true, "message": "推送成功"})
 }
 
+// ReceiveAIResponseStream 接收流式 AI 回复的单个分片，转成 AI_RESPONSE_DELTA 帧
+// 推给用户；背压交给 SessionService.SendMessageToUser 的下行队列处理，慢客户端
+// 不会拖慢上游调用方逐帧推送。
+func (h *APIHandler) ReceiveAIResponseStream(c *gin.Context) {
+	var req model.AIResponseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "invalid request"})
+		return
+	}
+
+	msg := model.ChatMessage{
+		MessageID:  uuid.New().String(),
+		Type:       "AI_RESPONSE_DELTA",
+		Content:    req.Content,
+		Sender:     0,
+		SenderName: "AI助手",
+		Timestamp:  time.Now(),
+		StreamID:   req.StreamID,
+		Seq:        req.Seq,
+		Done:       req.Done,
+	}
+
+	if err := h.sessionService.SendMessageToUser(req.UserID, msg); err != nil {
+		h.logger.Error("推送 AI 回复分片失败",
+			zap.Int64("userId", req.UserID),
+			zap.String("streamId", req.StreamID),
+			zap.Error(err))
+		c.JSON(500, gin.H{"success": false, "message": "推送失败"})
+		return
+	}
+
+	c.JSON(200, gin.H{"success": true})
+}
+
 // Health 健康检查
 func (h *APIHandler) Health(c *gin.Context) {
 	c.JSON(200, gin.H{
-		"status":        "UP",
-		"service":       c.GetString("service_name"),
-		"online_users":  h.sessionService.GetOnlineCount(),
+		"status":       "UP",
+		"service":      c.GetString("service_name"),
+		"online_users": h.sessionService.GetOnlineCount(),
 	})
 }
 
@@ -101,4 +135,3 @@ func (h *APIHandler) UserLogin(c *gin.Context) {
 		},
 	})
 }
-
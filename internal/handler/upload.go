@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/supportbot/supportbot-go/internal/service"
+	"github.com/supportbot/supportbot-go/internal/service/knowledge"
+	"go.uber.org/zap"
+)
+
+// uploadMetaPrefix 分片上传在 Redis 中记录已完成分片的 hash key 前缀，
+// field 为分片序号（字符串），value 为该分片的 MD5，供状态查询/续传判断
+const uploadMetaPrefix = "upload:"
+
+// uploadTotalField 记录本次上传的分片总数，和分片序号字段存在同一个 hash 里，
+// 用固定前缀区分，避免与合法的分片序号 "1", "2", ... 撞 key
+const uploadTotalField = "__total"
+
+// uploadFileNameField 记录原始文件名，供最终合并后交给 Ingestor 做类型识别
+const uploadFileNameField = "__fileName"
+
+// UploadHandler 处理知识库文档的分片/断点续传上传
+type UploadHandler struct {
+	knowledgeService *service.KnowledgeService
+	ingestor         *knowledge.Ingestor
+	redisClient      *redis.Client
+	stagingDir       string
+	logger           *zap.Logger
+}
+
+// NewUploadHandler 创建分片上传处理器，stagingDir 为空时默认 data/uploads
+func NewUploadHandler(knowledgeService *service.KnowledgeService, ingestor *knowledge.Ingestor,
+	redisClient *redis.Client, stagingDir string, logger *zap.Logger) *UploadHandler {
+
+	if stagingDir == "" {
+		stagingDir = "data/uploads"
+	}
+	return &UploadHandler{
+		knowledgeService: knowledgeService,
+		ingestor:         ingestor,
+		redisClient:      redisClient,
+		stagingDir:       stagingDir,
+		logger:           logger,
+	}
+}
+
+// UploadChunk 接收一个分片：校验分片 MD5、落盘暂存、在 Redis 中登记完成状态；
+// 收到最后一个分片后按序号拼接整个文件、校验整体 MD5，再交给 Ingestor 摄取。
+func (h *UploadHandler) UploadChunk(c *gin.Context) {
+	fileMd5 := c.PostForm("fileMd5")
+	fileName := c.PostForm("fileName")
+	chunkMd5 := c.PostForm("chunkMd5")
+	chunkNumber, err1 := strconv.Atoi(c.PostForm("chunkNumber"))
+	chunkTotal, err2 := strconv.Atoi(c.PostForm("chunkTotal"))
+
+	if fileMd5 == "" || fileName == "" || chunkMd5 == "" || err1 != nil || err2 != nil || chunkNumber < 1 || chunkTotal < 1 {
+		c.JSON(400, gin.H{"error": "fileMd5/fileName/chunkMd5/chunkNumber/chunkTotal 参数缺失或不合法"})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(400, gin.H{"error": "缺少 file 字段"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	if sum := md5Hex(data); sum != chunkMd5 {
+		h.logger.Warn("分片 MD5 校验失败", zap.String("fileMd5", fileMd5), zap.Int("chunkNumber", chunkNumber))
+		c.JSON(400, gin.H{"error": fmt.Sprintf("分片 %d MD5 校验失败", chunkNumber)})
+		return
+	}
+
+	fileDir := filepath.Join(h.stagingDir, fileMd5)
+	if err := os.MkdirAll(fileDir, 0o755); err != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("创建暂存目录失败: %v", err)})
+		return
+	}
+	chunkPath := filepath.Join(fileDir, strconv.Itoa(chunkNumber))
+	if err := os.WriteFile(chunkPath, data, 0o644); err != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("写入分片失败: %v", err)})
+		return
+	}
+
+	ctx := c.Request.Context()
+	metaKey := uploadMetaPrefix + fileMd5
+	if err := h.redisClient.HSet(ctx, metaKey,
+		strconv.Itoa(chunkNumber), chunkMd5,
+		uploadTotalField, chunkTotal,
+		uploadFileNameField, fileName,
+	).Err(); err != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("记录分片状态失败: %v", err)})
+		return
+	}
+
+	received, err := h.redisClient.HLen(ctx, metaKey).Result()
+	if err != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("查询分片状态失败: %v", err)})
+		return
+	}
+
+	// HLen 里除分片序号外还有 __total/__fileName 两个元字段
+	if int(received)-2 < chunkTotal {
+		c.JSON(200, gin.H{"status": "chunk_received", "chunkNumber": chunkNumber})
+		return
+	}
+
+	parentID, chunks, err := h.mergeAndIngest(ctx, fileMd5, fileName, chunkTotal, fileDir, metaKey)
+	if err != nil {
+		h.logger.Error("合并/摄取文件失败", zap.String("fileMd5", fileMd5), zap.Error(err))
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"status":   "success",
+		"parentId": parentID,
+		"chunks":   chunks,
+	})
+}
+
+// mergeAndIngest 按序号拼接所有分片、校验整体 MD5，通过后交给 Ingestor 切片向量化
+// 并写入知识库；无论成功与否都清理暂存目录和 Redis 状态，避免坏文件卡住后续重传。
+func (h *UploadHandler) mergeAndIngest(ctx context.Context, fileMd5, fileName string, chunkTotal int,
+	fileDir, metaKey string) (string, int, error) {
+
+	defer func() {
+		os.RemoveAll(fileDir)
+		h.redisClient.Del(ctx, metaKey)
+	}()
+
+	var data []byte
+	for i := 1; i <= chunkTotal; i++ {
+		chunk, err := os.ReadFile(filepath.Join(fileDir, strconv.Itoa(i)))
+		if err != nil {
+			return "", 0, fmt.Errorf("读取分片 %d 失败: %w", i, err)
+		}
+		data = append(data, chunk...)
+	}
+
+	if sum := md5Hex(data); sum != fileMd5 {
+		return "", 0, fmt.Errorf("整体文件 MD5 校验失败，期望 %s 实际 %s", fileMd5, sum)
+	}
+
+	docs, err := h.ingestor.IngestFile(fileMd5, fileName, data)
+	if err != nil {
+		return "", 0, fmt.Errorf("文档摄取失败: %w", err)
+	}
+
+	if err := h.knowledgeService.AddKnowledgeChunks(docs); err != nil {
+		return "", 0, fmt.Errorf("写入知识库失败: %w", err)
+	}
+
+	return fileMd5, len(docs), nil
+}
+
+// UploadStatus 返回某个文件已收到的分片序号和缺失的分片序号，供客户端据此续传
+func (h *UploadHandler) UploadStatus(c *gin.Context) {
+	fileMd5 := c.Query("fileMd5")
+	if fileMd5 == "" {
+		c.JSON(400, gin.H{"error": "fileMd5 参数不能为空"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	metaKey := uploadMetaPrefix + fileMd5
+	fields, err := h.redisClient.HGetAll(ctx, metaKey).Result()
+	if err != nil {
+		c.JSON(500, gin.H{"error": fmt.Sprintf("查询分片状态失败: %v", err)})
+		return
+	}
+
+	if len(fields) == 0 {
+		c.JSON(200, gin.H{"fileMd5": fileMd5, "total": 0, "received": []int{}, "missing": []int{}})
+		return
+	}
+
+	total, _ := strconv.Atoi(fields[uploadTotalField])
+	received := make([]int, 0, len(fields))
+	for key := range fields {
+		if key == uploadTotalField || key == uploadFileNameField {
+			continue
+		}
+		if n, err := strconv.Atoi(key); err == nil {
+			received = append(received, n)
+		}
+	}
+
+	receivedSet := make(map[int]bool, len(received))
+	for _, n := range received {
+		receivedSet[n] = true
+	}
+	missing := make([]int, 0)
+	for i := 1; i <= total; i++ {
+		if !receivedSet[i] {
+			missing = append(missing, i)
+		}
+	}
+
+	c.JSON(200, gin.H{
+		"fileMd5":  fileMd5,
+		"total":    total,
+		"received": received,
+		"missing":  missing,
+	})
+}
+
+// md5Hex 计算字节切片的 MD5 十六进制摘要
+func md5Hex(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
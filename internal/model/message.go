@@ -5,12 +5,15 @@ import "time"
 // ChatMessage 聊天消息
 type ChatMessage struct {
 	MessageID  string    `json:"messageId"`
-	Type       string    `json:"type"` // CHAT, HEARTBEAT, AI_RESPONSE
+	Type       string    `json:"type"` // CHAT, HEARTBEAT, AI_RESPONSE, AI_RESPONSE_DELTA
 	Content    string    `json:"content"`
 	Sender     int64     `json:"sender"`
 	SenderName string    `json:"senderName,omitempty"`
 	SessionID  string    `json:"sessionId,omitempty"`
 	Timestamp  time.Time `json:"timestamp"`
+	StreamID   string    `json:"streamId,omitempty"` // AI_RESPONSE_DELTA 所属的流 ID
+	Seq        int       `json:"seq,omitempty"`      // 流内序号，从 0 开始递增
+	Done       bool      `json:"done,omitempty"`     // 是否为该流的最后一帧
 }
 
 // ChatResponse 聊天响应
@@ -22,9 +25,12 @@ type ChatResponse struct {
 
 // AIResponseRequest AI 回复请求
 type AIResponseRequest struct {
-	UserID  int64  `json:"userId"`
-	Content string `json:"content"`
-	Source  string `json:"source"` // assistant, rag, chat
+	UserID   int64  `json:"userId"`
+	Content  string `json:"content"`
+	Source   string `json:"source"`             // assistant, knowledge-rag, general-chat
+	StreamID string `json:"streamId,omitempty"` // 非空表示这是一次流式回复中的一帧
+	Seq      int    `json:"seq,omitempty"`      // 流内序号，从 0 开始递增
+	Done     bool   `json:"done,omitempty"`     // 是否为该流的最后一帧
 }
 
 // ClassifyRequest 问题分类请求
@@ -7,6 +7,10 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+// sessionOutboxCapacity 下行队列容量，为 WebSocket 推送提供背压缓冲：
+// 当客户端消费跟不上（慢连接）时，新帧不会阻塞调用方，而是挤掉队列里最旧的帧。
+const sessionOutboxCapacity = 64
+
 // UserSession 用户会话
 type UserSession struct {
 	UserID        int64
@@ -16,7 +20,22 @@ type UserSession struct {
 	ClientIP      string
 	LastHeartbeat time.Time
 	MissedBeats   int
-	mu            sync.RWMutex // 保护会话字段
+	Outbox        chan interface{} // 异步下行队列，由 SessionService 的写协程消费
+	mu            sync.RWMutex     // 保护会话字段
+	closeOutbox   sync.Once        // 保证 Outbox 只被关闭一次（多处清理路径可能并发触发）
+}
+
+// NewUserSession 创建用户会话，并初始化下行背压队列
+func NewUserSession(userID int64, username string, conn *websocket.Conn, sessionID, clientIP string) *UserSession {
+	return &UserSession{
+		UserID:        userID,
+		Username:      username,
+		Conn:          conn,
+		SessionID:     sessionID,
+		ClientIP:      clientIP,
+		LastHeartbeat: time.Now(),
+		Outbox:        make(chan interface{}, sessionOutboxCapacity),
+	}
 }
 
 // UpdateHeartbeat 更新心跳时间
@@ -47,3 +66,10 @@ func (s *UserSession) WriteMessage(message interface{}) error {
 	defer s.mu.Unlock()
 	return s.Conn.WriteJSON(message)
 }
+
+// CloseOutbox 关闭下行队列，结束对应的写协程；可安全地多次调用
+func (s *UserSession) CloseOutbox() {
+	s.closeOutbox.Do(func() {
+		close(s.Outbox)
+	})
+}
@@ -0,0 +1,166 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/supportbot/supportbot-go/internal/client"
+	"github.com/supportbot/supportbot-go/internal/tools"
+	"go.uber.org/zap"
+)
+
+// maxIterations 限制一次对话中模型请求工具调用的轮数，避免模型陷入死循环
+const maxIterations = 5
+
+// maxParallelTools 同一轮内并发执行工具调用的上限，避免一次带多个工具调用的
+// 响应把下游服务（订单/库存/知识库）瞬间打满
+const maxParallelTools = 4
+
+// toolCallTimeout 单次工具调用的超时时间
+const toolCallTimeout = 10 * time.Second
+
+// TraceEvent 记录 Agent 执行循环中的一步，用于回放/调试整个工具调用过程
+type TraceEvent struct {
+	Step      int       `json:"step"`
+	Type      string    `json:"type"` // llm_call, tool_call, tool_result, final_answer, max_iterations
+	Tool      string    `json:"tool,omitempty"`
+	Content   string    `json:"content,omitempty"`
+	Err       string    `json:"err,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Agent 串联 LLM 与工具调用的执行循环：把用户问题和工具定义一起交给模型，
+// 如果模型返回 tool_calls 就并发执行（互相独立，无需串行等待）并把结果喂回模型，
+// 直到模型给出最终回答或达到轮数上限。
+type Agent struct {
+	llmClient    *client.DashScopeClient
+	toolRegistry *tools.Registry
+	systemPrompt string
+	logger       *zap.Logger
+}
+
+// NewAgent 创建 Agent
+func NewAgent(llmClient *client.DashScopeClient, toolRegistry *tools.Registry, systemPrompt string, logger *zap.Logger) *Agent {
+	return &Agent{
+		llmClient:    llmClient,
+		toolRegistry: toolRegistry,
+		systemPrompt: systemPrompt,
+		logger:       logger,
+	}
+}
+
+// Run 执行一次完整的工具调用循环，返回模型最终的文本回答，以及本次执行的完整轨迹
+// （每一步 LLM 调用、工具调用、工具结果），供上层记录或回放调试。historyContext 是
+// MemoryService.BuildContext 拼好的摘要+最近对话，为空表示该用户还没有历史记忆。
+func (a *Agent) Run(question string, historyContext string) (string, []TraceEvent, error) {
+	userMessage := question
+	if historyContext != "" {
+		userMessage = fmt.Sprintf("%s\n用户问题：%s", historyContext, question)
+	}
+	messages := []client.Message{{Role: "user", Content: userMessage}}
+	toolDefs := a.toolRegistry.AsFunctionDefs()
+
+	var trace []TraceEvent
+	step := 0
+	record := func(ev TraceEvent) {
+		step++
+		ev.Step = step
+		ev.Timestamp = time.Now()
+		trace = append(trace, ev)
+	}
+
+	for i := 0; i < maxIterations; i++ {
+		content, toolCalls, err := a.llmClient.ChatWithTools(a.systemPrompt, messages, toolDefs)
+		if err != nil {
+			record(TraceEvent{Type: "llm_call", Err: err.Error()})
+			return "", trace, fmt.Errorf("调用 LLM 失败: %w", err)
+		}
+		record(TraceEvent{Type: "llm_call", Content: content})
+
+		if len(toolCalls) == 0 {
+			record(TraceEvent{Type: "final_answer", Content: content})
+			return content, trace, nil
+		}
+
+		messages = append(messages, client.Message{Role: "assistant", Content: content, ToolCalls: toolCalls})
+
+		results := a.executeToolCalls(toolCalls)
+		for j, call := range toolCalls {
+			record(TraceEvent{Type: "tool_call", Tool: call.Function.Name, Content: call.Function.Arguments})
+			record(TraceEvent{Type: "tool_result", Tool: call.Function.Name, Content: results[j].Content})
+			messages = append(messages, results[j])
+		}
+	}
+
+	record(TraceEvent{Type: "max_iterations"})
+	a.logger.Warn("达到最大工具调用轮数，强制结束", zap.Int("maxIterations", maxIterations))
+	return "抱歉，暂时无法处理您的问题，请稍后重试。", trace, nil
+}
+
+// executeToolCalls 并发执行一轮内互相独立的工具调用，用有界 worker pool
+// （maxParallelTools）限制并发数，结果按原始顺序写回，保证与 toolCalls 一一对应。
+func (a *Agent) executeToolCalls(calls []tools.ToolCall) []client.Message {
+	results := make([]client.Message, len(calls))
+	sem := make(chan struct{}, maxParallelTools)
+	var wg sync.WaitGroup
+
+	for i, call := range calls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, call tools.ToolCall) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = a.executeToolCall(call)
+		}(i, call)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// executeToolCall 执行单个工具调用（带超时），并构造回填给模型的 tool 角色消息。
+// 超时通过 ctx 传给 Handler，同时用独立的结果 channel 兜底：即使 Handler 本身不
+// 检查 ctx 也能让调用方按时拿到"超时"错误，不会被一个卡死的工具拖住整条链路。
+func (a *Agent) executeToolCall(call tools.ToolCall) client.Message {
+	ctx, cancel := context.WithTimeout(context.Background(), toolCallTimeout)
+	defer cancel()
+
+	type execResult struct {
+		result interface{}
+		err    error
+	}
+	done := make(chan execResult, 1)
+	go func() {
+		result, err := a.toolRegistry.Execute(ctx, call)
+		done <- execResult{result, err}
+	}()
+
+	var result interface{}
+	var err error
+	select {
+	case res := <-done:
+		result, err = res.result, res.err
+	case <-ctx.Done():
+		err = fmt.Errorf("工具调用超时: %s", call.Function.Name)
+	}
+
+	var content string
+	if err != nil {
+		a.logger.Error("工具调用失败", zap.String("tool", call.Function.Name), zap.Error(err))
+		content = fmt.Sprintf(`{"error": %q}`, err.Error())
+	} else if data, marshalErr := json.Marshal(result); marshalErr == nil {
+		content = string(data)
+	} else {
+		content = fmt.Sprintf("%v", result)
+	}
+
+	return client.Message{
+		Role:       "tool",
+		Content:    content,
+		ToolCallID: call.ID,
+		Name:       call.Function.Name,
+	}
+}
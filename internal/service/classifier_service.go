@@ -3,25 +3,58 @@ package service
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/supportbot/supportbot-go/internal/client"
 	"github.com/supportbot/supportbot-go/internal/model"
+	"github.com/supportbot/supportbot-go/internal/vectorstore"
 	"go.uber.org/zap"
 )
 
+// classifyCacheTTL 问题分类结果在 Redis 中的缓存时长
+const classifyCacheTTL = 24 * time.Hour
+
+// classifyCandidateCount 双路检索融合排序后，交给 LLM 消歧的候选分类数量上限
+const classifyCandidateCount = 3
+
+// classifyDefaultFallbackThreshold fallbackThreshold 未配置（<= 0）时使用的默认值：
+// 融合分低于该值直接回退到 general-chat，不再调用 LLM。单路检索排名第 1 的 RRF 得分
+// 只有 1/61≈0.016，所以只被一路检索召回的分类（例如 BM25 没有命中、或问题向量化失败时）
+// 总会低于该值而回退
+const classifyDefaultFallbackThreshold = 0.03
+
 // ClassifierService 问题分类服务
 type ClassifierService struct {
-	llmClient   *client.DashScopeClient
-	redisClient *redis.Client
-	httpClient  *http.Client
-	agentURLs   map[string]string // category -> agent URL
-	logger      *zap.Logger
-	systemPrompt string
-	categories   map[string]CategoryInfo
+	llmClient         client.LLMProvider
+	embeddingClient   *client.EmbeddingClient
+	redisClient       *redis.Client
+	memoryService     *MemoryService
+	httpClient        *http.Client
+	agentURLsMu       sync.RWMutex
+	agentURLs         map[string]string // category -> agent URL，支持 UpdateAgentURLs 热更新
+	logger            *zap.Logger
+	systemPrompt      string
+	categories        map[string]CategoryInfo
+	categoryVectors   map[string][]float64
+	bm25Index         *vectorstore.BM25Index
+	fallbackThreshold float64
+}
+
+// categoryScore 一个分类在双路检索融合排序后的得分，分值来自 RRF，量纲上不可
+// 跨 query 比较，仅用于同一次分类内部排序和回退阈值判断
+type categoryScore struct {
+	Category string
+	Score    float64
 }
 
 // CategoryInfo 分类信息
@@ -32,12 +65,18 @@ type CategoryInfo struct {
 	AgentURL    string   `json:"agentUrl"`
 }
 
-// NewClassifierService 创建问题分类服务
+// NewClassifierService 创建问题分类服务。启动时会用 embeddingClient 把每个分类的
+// Name+Description+Keywords 转成向量并缓存在内存中，同时写入 BM25 索引，供后续
+// 分类请求做向量+BM25 双路检索。fallbackThreshold 留空（<=0）时使用
+// classifyDefaultFallbackThreshold。
 func NewClassifierService(
-	llmClient *client.DashScopeClient,
+	llmClient client.LLMProvider,
+	embeddingClient *client.EmbeddingClient,
 	redisClient *redis.Client,
+	memoryService *MemoryService,
 	categories map[string]CategoryInfo,
 	systemPrompt string,
+	fallbackThreshold float64,
 	logger *zap.Logger,
 ) *ClassifierService {
 	agentURLs := make(map[string]string)
@@ -45,15 +84,68 @@ func NewClassifierService(
 		agentURLs[category] = info.AgentURL
 	}
 
-	return &ClassifierService{
-		llmClient:    llmClient,
-		redisClient:  redisClient,
-		httpClient:   &http.Client{},
-		agentURLs:    agentURLs,
-		categories:   categories,
-		systemPrompt: systemPrompt,
-		logger:       logger,
+	if fallbackThreshold <= 0 {
+		fallbackThreshold = classifyDefaultFallbackThreshold
 	}
+
+	s := &ClassifierService{
+		llmClient:         llmClient,
+		embeddingClient:   embeddingClient,
+		redisClient:       redisClient,
+		memoryService:     memoryService,
+		httpClient:        &http.Client{},
+		agentURLs:         agentURLs,
+		categories:        categories,
+		systemPrompt:      systemPrompt,
+		logger:            logger,
+		categoryVectors:   make(map[string][]float64),
+		bm25Index:         vectorstore.NewBM25Index(),
+		fallbackThreshold: fallbackThreshold,
+	}
+
+	s.loadCategoryIndex()
+
+	return s
+}
+
+// UpdateAgentURLs 用新的 category -> agent URL 映射整体替换路由表，供配置热加载
+// （见 config.Watch）在 services.* 地址变更时调用，routeToAgent 始终读到一份完整
+// 的新映射，不会出现新旧地址混用。
+func (s *ClassifierService) UpdateAgentURLs(agentURLs map[string]string) {
+	s.agentURLsMu.Lock()
+	s.agentURLs = agentURLs
+	s.agentURLsMu.Unlock()
+}
+
+// categoryText 拼出用于生成分类向量与写入 BM25 索引的文本，两路检索对同一分类
+// 用同一份文本，保证它们判断的是同一件事
+func categoryText(info CategoryInfo) string {
+	text := info.Name + " " + info.Description
+	if len(info.Keywords) > 0 {
+		text += " " + fmt.Sprint(info.Keywords)
+	}
+	return text
+}
+
+// loadCategoryIndex 为每个分类生成语义向量并写入 BM25 索引。向量化失败时跳过该
+// 分类的向量，BM25 一侧仍然生效，两路检索各自独立失效互不影响。
+func (s *ClassifierService) loadCategoryIndex() {
+	for category, info := range s.categories {
+		text := categoryText(info)
+		s.bm25Index.AddDocument(category, text)
+
+		vector, err := s.embeddingClient.GetEmbedding(text)
+		if err != nil {
+			s.logger.Error("生成分类向量失败，该分类将只依赖 BM25 检索",
+				zap.String("category", category), zap.Error(err))
+			continue
+		}
+		s.categoryVectors[category] = vector
+	}
+
+	s.logger.Info("分类索引初始化完成",
+		zap.Int("vectors", len(s.categoryVectors)),
+		zap.Int("bm25Docs", s.bm25Index.Count()))
 }
 
 // ClassifyAndRoute 分类并路由问题
@@ -62,78 +154,221 @@ func (s *ClassifierService) ClassifyAndRoute(userID int64, question string) (*mo
 		zap.Int64("userId", userID),
 		zap.String("question", question))
 
-	// 1. 从 Redis 获取对话历史
 	ctx := context.Background()
-	historyKey := fmt.Sprintf("chat_history:%d", userID)
-	history, _ := s.redisClient.LRange(ctx, historyKey, -5, -1).Result()
 
-	// 2. 构建分类提示词
-	prompt := s.buildClassifyPrompt(question, history)
+	// 0. 命中 Redis 缓存则直接复用，跳过分类本身
+	if cached, ok := s.getCachedClassification(ctx, question); ok {
+		s.logger.Info("命中分类缓存",
+			zap.Int64("userId", userID),
+			zap.String("category", cached.Category))
+		s.recordUserTurn(userID, question)
+		go s.routeToAgent(userID, question, cached.Category)
+		return cached, nil
+	}
 
-	// 3. 调用 LLM 分类
-	response, err := s.llmClient.SimpleChat(s.systemPrompt, prompt)
+	// 1. 取滚动窗口对话记忆（摘要 + 最近轮次），供消歧 Prompt 使用
+	history, err := s.memoryService.BuildContext(userID, 0)
 	if err != nil {
-		return nil, fmt.Errorf("LLM 分类失败: %w", err)
+		s.logger.Warn("读取对话记忆失败，消歧将不带历史上下文", zap.Int64("userId", userID), zap.Error(err))
 	}
 
-	// 4. 解析分类结果
-	category := s.parseCategory(response)
-	
-	result := &model.ClassifyResponse{
-		Category:    category,
-		Confidence:  0.9,
-		Description: s.categories[category].Description,
+	// 2. BM25 + 向量双路检索，RRF 融合排序得到每个分类的候选得分
+	ranked := s.rankCategories(question)
+
+	var result *model.ClassifyResponse
+	switch {
+	case len(ranked) == 0:
+		// 3a. 两路检索都没有结果（BM25 只返回含有查询词的分类，问题与所有分类都没有
+		// 共同的词且向量检索也没有结果时即为空），保底回退到 general-chat
+		result = &model.ClassifyResponse{
+			Category:    "general-chat",
+			Confidence:  0,
+			Description: s.categories["general-chat"].Description,
+		}
+	case ranked[0].Score < s.fallbackThreshold:
+		// 3b. 融合分低于阈值（默认阈值下，只被一路检索召回的分类都会落到这里），直接
+		// 回退到 general-chat，不再浪费一次 LLM 调用
+		result = &model.ClassifyResponse{
+			Category:    "general-chat",
+			Confidence:  ranked[0].Score,
+			Description: s.categories["general-chat"].Description,
+		}
+	default:
+		// 3c. 取 Top-N 候选交给 LLM 在有限范围内消歧，而不是让它在全部分类里自由发挥
+		candidates := ranked
+		if len(candidates) > classifyCandidateCount {
+			candidates = candidates[:classifyCandidateCount]
+		}
+
+		disambiguated, err := s.disambiguateByLLM(question, history, candidates)
+		if err != nil {
+			return nil, err
+		}
+		result = disambiguated
 	}
 
 	s.logger.Info("问题分类完成",
 		zap.Int64("userId", userID),
-		zap.String("category", category))
+		zap.String("category", result.Category),
+		zap.Float64("confidence", result.Confidence))
 
-	// 5. 保存对话历史到 Redis
-	s.saveHistory(ctx, userID, question)
+	// 4. 写入分类缓存与对话记忆
+	s.cacheClassification(ctx, question, result)
+	s.recordUserTurn(userID, question)
 
-	// 6. 路由到对应的 Agent
-	go s.routeToAgent(userID, question, category)
+	// 5. 路由到对应的 Agent
+	go s.routeToAgent(userID, question, result.Category)
 
 	return result, nil
 }
 
-// buildClassifyPrompt 构建分类提示词
-func (s *ClassifierService) buildClassifyPrompt(question string, history []string) string {
-	prompt := "请根据以下问题进行分类：\n\n"
-	
-	if len(history) > 0 {
-		prompt += "对话历史：\n"
-		for _, h := range history {
-			prompt += h + "\n"
+// rankCategories 对所有分类做 BM25 + 向量双路检索：BM25 一侧直接对分类文本的倒排
+// 索引检索，向量一侧对问题 embedding 与每个分类的 categoryVectors 做余弦相似度
+// 排序，再用 ReciprocalRankFusion（k=60）融合两路排名，按融合分降序返回。
+// 问题向量化失败时退化为只用 BM25 一路的排名。
+func (s *ClassifierService) rankCategories(question string) []categoryScore {
+	bm25Results := s.bm25Index.Search(question, len(s.categories))
+	bm25Ranking := make([]string, len(bm25Results))
+	for i, r := range bm25Results {
+		bm25Ranking[i] = r.DocID
+	}
+
+	rankings := [][]string{bm25Ranking}
+
+	queryVector, err := s.embeddingClient.GetQueryEmbedding(question)
+	if err != nil {
+		s.logger.Warn("生成问题向量失败，分类将只依赖 BM25 检索", zap.Error(err))
+	} else if len(s.categoryVectors) > 0 {
+		type vectorScore struct {
+			category string
+			score    float64
+		}
+		scored := make([]vectorScore, 0, len(s.categoryVectors))
+		for category, vector := range s.categoryVectors {
+			scored = append(scored, vectorScore{category, cosineSimilarity(queryVector, vector)})
+		}
+		sort.Slice(scored, func(i, j int) bool { return scored[i].score > scored[j].score })
+
+		vectorRanking := make([]string, len(scored))
+		for i, v := range scored {
+			vectorRanking[i] = v.category
 		}
-		prompt += "\n"
+		rankings = append(rankings, vectorRanking)
 	}
-	
-	prompt += "用户问题：" + question + "\n\n"
-	prompt += "可选分类：\n"
-	for category, info := range s.categories {
-		prompt += fmt.Sprintf("- %s: %s\n", category, info.Description)
+
+	fused := vectorstore.ReciprocalRankFusion(rankings...)
+
+	results := make([]categoryScore, 0, len(fused))
+	for category, score := range fused {
+		results = append(results, categoryScore{Category: category, Score: score})
 	}
-	
-	prompt += "\n请直接返回分类名称，只返回一个词。"
-	return prompt
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	return results
 }
 
-// parseCategory 解析分类结果
-func (s *ClassifierService) parseCategory(response string) string {
-	// 简单匹配（实际应该更智能）
-	for category := range s.categories {
-		if contains(response, category) {
-			return category
+// disambiguateByLLM 让 LLM 只在 candidates 范围内挑选最终分类，而不是像之前那样
+// 在全部分类里自由发挥。Confidence 取所选分类的融合分，不再是写死的 0.9。
+func (s *ClassifierService) disambiguateByLLM(question string, history string, candidates []categoryScore) (*model.ClassifyResponse, error) {
+	prompt := s.buildClassifyPrompt(question, history, candidates)
+	response, err := client.SimpleChat(s.llmClient, s.systemPrompt, prompt)
+	if err != nil {
+		return nil, fmt.Errorf("LLM 分类失败: %w", err)
+	}
+
+	chosen := candidates[0]
+	for _, c := range candidates {
+		if contains(response, c.Category) {
+			chosen = c
+			break
 		}
 	}
-	return "general-chat" // 默认分类
+
+	return &model.ClassifyResponse{
+		Category:    chosen.Category,
+		Confidence:  chosen.Score,
+		Description: s.categories[chosen.Category].Description,
+	}, nil
+}
+
+// cosineSimilarity 计算余弦相似度（0-1，越高越相似）
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// classifyCacheKey 根据问题内容生成缓存键
+func classifyCacheKey(question string) string {
+	sum := sha256.Sum256([]byte(question))
+	return "classify:cache:" + hex.EncodeToString(sum[:])
+}
+
+// getCachedClassification 从 Redis 读取问题分类缓存
+func (s *ClassifierService) getCachedClassification(ctx context.Context, question string) (*model.ClassifyResponse, bool) {
+	data, err := s.redisClient.Get(ctx, classifyCacheKey(question)).Result()
+	if err != nil {
+		return nil, false
+	}
+
+	var result model.ClassifyResponse
+	if err := json.Unmarshal([]byte(data), &result); err != nil {
+		return nil, false
+	}
+
+	return &result, true
+}
+
+// cacheClassification 把分类结果写入 Redis，便于重复问题命中缓存
+func (s *ClassifierService) cacheClassification(ctx context.Context, question string, result *model.ClassifyResponse) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		s.logger.Error("序列化分类结果失败", zap.Error(err))
+		return
+	}
+
+	if err := s.redisClient.Set(ctx, classifyCacheKey(question), data, classifyCacheTTL).Err(); err != nil {
+		s.logger.Error("写入分类缓存失败", zap.Error(err))
+	}
+}
+
+// buildClassifyPrompt 构建分类提示词，只列出 candidates 中的分类供 LLM 消歧，
+// 而不是列出全部分类。history 是 MemoryService.BuildContext 拼好的摘要+最近对话，
+// 为空表示该用户还没有历史记忆。
+func (s *ClassifierService) buildClassifyPrompt(question string, history string, candidates []categoryScore) string {
+	prompt := "请根据以下问题，从候选分类中选出最合适的一个：\n\n"
+
+	if history != "" {
+		prompt += history + "\n"
+	}
+
+	prompt += "用户问题：" + question + "\n\n"
+	prompt += "候选分类：\n"
+	for _, c := range candidates {
+		prompt += fmt.Sprintf("- %s: %s\n", c.Category, s.categories[c.Category].Description)
+	}
+
+	prompt += "\n请直接返回分类名称，只返回一个词。"
+	return prompt
 }
 
 // routeToAgent 路由到 Agent
 func (s *ClassifierService) routeToAgent(userID int64, question string, category string) {
+	s.agentURLsMu.RLock()
 	agentURL, ok := s.agentURLs[category]
+	s.agentURLsMu.RUnlock()
 	if !ok {
 		s.logger.Error("未找到 Agent URL", zap.String("category", category))
 		return
@@ -166,16 +401,17 @@ func (s *ClassifierService) routeToAgent(userID int64, question string, category
 		zap.String("category", category))
 }
 
-// saveHistory 保存对话历史
-func (s *ClassifierService) saveHistory(ctx context.Context, userID int64, message string) {
-	historyKey := fmt.Sprintf("chat_history:%d", userID)
-	s.redisClient.RPush(ctx, historyKey, message)
-	s.redisClient.Expire(ctx, historyKey, 3600*24) // 24 小时过期
+// recordUserTurn 把用户问题记入对话记忆，供后续请求的 BuildContext 复用；
+// 对应的 assistant 回复由实际生成回复的下游服务（assistant/general-chat/
+// knowledge-rag）调用 MemoryService.AppendTurn 记录，ClassifierService 本身不
+// 等待也看不到最终回复内容。
+func (s *ClassifierService) recordUserTurn(userID int64, message string) {
+	if err := s.memoryService.AppendTurn(userID, "user", message); err != nil {
+		s.logger.Error("记录对话记忆失败", zap.Int64("userId", userID), zap.Error(err))
+	}
 }
 
-// 辅助函数
+// contains 判断 str 中是否出现 substr，用于在 LLM 自由文本回复里匹配分类名
 func contains(str, substr string) bool {
-	return len(str) >= len(substr) && (str == substr || 
-		len(str) > len(substr) && (str[:len(substr)] == substr || str[len(str)-len(substr):] == substr))
+	return strings.Contains(str, substr)
 }
-
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// clusterUserKeyPrefix Redis 中记录 userID -> nodeID 归属的 key 前缀，带 TTL，
+// 由 SessionService 的心跳检测协程周期性续期
+const clusterUserKeyPrefix = "session:user:"
+
+// clusterOnlineSetKey 记录集群内所有在线 userID 的 Redis Set，GetOnlineCount
+// 对它做 SCARD 得到集群整体在线数
+const clusterOnlineSetKey = "session:online"
+
+// clusterNodeChannelPrefix 每个节点订阅的 Redis pub/sub 频道前缀，其他节点把
+// 需要转发给该节点本地用户的消息发到 clusterNodeChannelPrefix+nodeID
+const clusterNodeChannelPrefix = "session:node:"
+
+// ClusterBroker 跨节点会话路由的抽象：记录 userID 当前归属哪个节点，并在节点间
+// 转发消息，使 SessionService 在多副本部署下也能把消息送到用户实际连接的那个
+// 节点。按这个接口实现即可换成 NATS/Kafka 等其他消息总线，SessionService 不感知
+// 具体实现。
+type ClusterBroker interface {
+	// Register 把 userID 的归属登记为 nodeID，ttl 内未被 Refresh 续期则自动过期。
+	// 返回值 previousNodeID 是登记前 userID 归属的节点：为空表示之前不在线，
+	// 等于 nodeID 表示就是本节点（无需接管），其余情况下调用方应向
+	// previousNodeID 发送关闭命令完成优雅接管。
+	Register(ctx context.Context, userID int64, nodeID string, ttl time.Duration) (previousNodeID string, err error)
+	// Refresh 续期 userID 在 nodeID 上的归属；若归属已被其他节点抢占则不做任何事
+	Refresh(ctx context.Context, userID int64, nodeID string, ttl time.Duration) error
+	// Unregister 清理 userID 的归属记录；仅当归属仍是 nodeID 时才会真正清理，
+	// 避免误删其他节点抢注后的记录
+	Unregister(ctx context.Context, userID int64, nodeID string) error
+	// Lookup 查询 userID 当前归属的节点，ok=false 表示集群内不在线
+	Lookup(ctx context.Context, userID int64) (nodeID string, ok bool, err error)
+	// Publish 把消息投递到目标节点订阅的频道
+	Publish(ctx context.Context, nodeID string, payload []byte) error
+	// Subscribe 订阅发往 nodeID 的消息，handler 在独立协程中被调用，直到 ctx 取消
+	Subscribe(ctx context.Context, nodeID string, handler func(payload []byte)) error
+	// OnlineCount 返回集群内在线用户总数
+	OnlineCount(ctx context.Context) (int64, error)
+}
+
+// RedisClusterBroker 基于 Redis 字符串 KV（归属记录）+ Set（在线计数）+
+// pub/sub（跨节点转发）实现的 ClusterBroker
+type RedisClusterBroker struct {
+	redisClient *redis.Client
+}
+
+// NewRedisClusterBroker 创建基于 Redis 的 ClusterBroker
+func NewRedisClusterBroker(redisClient *redis.Client) *RedisClusterBroker {
+	return &RedisClusterBroker{redisClient: redisClient}
+}
+
+func userKey(userID int64) string {
+	return fmt.Sprintf("%s%d", clusterUserKeyPrefix, userID)
+}
+
+func nodeChannel(nodeID string) string {
+	return clusterNodeChannelPrefix + nodeID
+}
+
+// Register 实现 ClusterBroker。GETSET 原子地换入 nodeID 并拿到旧值，随后单独
+// 设置 TTL——这会有极短的窗口内没有过期时间，可接受：Refresh 会在下一次心跳
+// 周期内补上，不会导致归属记录永久残留。
+func (b *RedisClusterBroker) Register(ctx context.Context, userID int64, nodeID string, ttl time.Duration) (string, error) {
+	key := userKey(userID)
+	previous, err := b.redisClient.GetSet(ctx, key, nodeID).Result()
+	if err != nil && err != redis.Nil {
+		return "", fmt.Errorf("登记会话归属失败: %w", err)
+	}
+	if err := b.redisClient.Expire(ctx, key, ttl).Err(); err != nil {
+		return "", fmt.Errorf("设置会话归属 TTL 失败: %w", err)
+	}
+	if err := b.redisClient.SAdd(ctx, clusterOnlineSetKey, userID).Err(); err != nil {
+		return "", fmt.Errorf("更新在线用户集合失败: %w", err)
+	}
+	return previous, nil
+}
+
+// Refresh 实现 ClusterBroker，仅在归属仍是 nodeID 时续期，避免误续期已被
+// 其他节点接管的记录
+func (b *RedisClusterBroker) Refresh(ctx context.Context, userID int64, nodeID string, ttl time.Duration) error {
+	key := userKey(userID)
+	current, err := b.redisClient.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("查询会话归属失败: %w", err)
+	}
+	if current != nodeID {
+		return nil
+	}
+	return b.redisClient.Expire(ctx, key, ttl).Err()
+}
+
+// Unregister 实现 ClusterBroker
+func (b *RedisClusterBroker) Unregister(ctx context.Context, userID int64, nodeID string) error {
+	key := userKey(userID)
+	current, err := b.redisClient.Get(ctx, key).Result()
+	if err != nil && err != redis.Nil {
+		return fmt.Errorf("查询会话归属失败: %w", err)
+	}
+	if current != "" && current != nodeID {
+		return nil
+	}
+	if current == nodeID {
+		if err := b.redisClient.Del(ctx, key).Err(); err != nil {
+			return fmt.Errorf("清理会话归属失败: %w", err)
+		}
+	}
+	return b.redisClient.SRem(ctx, clusterOnlineSetKey, userID).Err()
+}
+
+// Lookup 实现 ClusterBroker
+func (b *RedisClusterBroker) Lookup(ctx context.Context, userID int64) (string, bool, error) {
+	nodeID, err := b.redisClient.Get(ctx, userKey(userID)).Result()
+	if err == redis.Nil {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("查询会话归属失败: %w", err)
+	}
+	return nodeID, true, nil
+}
+
+// Publish 实现 ClusterBroker
+func (b *RedisClusterBroker) Publish(ctx context.Context, nodeID string, payload []byte) error {
+	return b.redisClient.Publish(ctx, nodeChannel(nodeID), payload).Err()
+}
+
+// Subscribe 实现 ClusterBroker，内部起一个协程持续消费 pub/sub 频道直到 ctx 取消
+func (b *RedisClusterBroker) Subscribe(ctx context.Context, nodeID string, handler func(payload []byte)) error {
+	pubsub := b.redisClient.Subscribe(ctx, nodeChannel(nodeID))
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return fmt.Errorf("订阅节点频道失败: %w", err)
+	}
+
+	go func() {
+		defer pubsub.Close()
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				handler([]byte(msg.Payload))
+			}
+		}
+	}()
+
+	return nil
+}
+
+// OnlineCount 实现 ClusterBroker
+func (b *RedisClusterBroker) OnlineCount(ctx context.Context) (int64, error) {
+	return b.redisClient.SCard(ctx, clusterOnlineSetKey).Result()
+}
@@ -0,0 +1,238 @@
+package knowledge
+
+import "strings"
+
+// defaultChunkSize 目标分片长度（字符数），取经验值以兼顾召回粒度与上下文完整性
+const defaultChunkSize = 500
+
+// defaultChunkOverlap 相邻分片的重叠长度，避免关键信息被硬切在分片边界上
+const defaultChunkOverlap = 50
+
+// splitSeparators 递归字符分割器尝试的分隔符，按优先级从高到低排列：
+// 先按段落、再按换行、再按中文句末标点、最后按空格兜底。
+var splitSeparators = []string{"\n\n", "\n", "。", "！", "？", " "}
+
+// sentenceSeparators 句子感知分割器使用的句末标点（中英文）
+var sentenceSeparators = []string{"。", "！", "？", ". ", "! ", "? "}
+
+// SplitStrategy 分片策略
+type SplitStrategy string
+
+const (
+	// SplitRecursive 递归字符分割：依次尝试段落/换行/句末标点/空格，是默认策略
+	SplitRecursive SplitStrategy = "recursive"
+	// SplitFixed 固定窗口分割：不考虑语义边界，按字符数切窗口，实现最简单、最可预测
+	SplitFixed SplitStrategy = "fixed"
+	// SplitSentence 句子感知分割：先按句末标点切句子，再合并到接近 chunkSize，避免把句子切断
+	SplitSentence SplitStrategy = "sentence"
+)
+
+// ChunkOptions 分片参数。ChunkSize/ChunkOverlap 传 0 使用默认值（500/50），
+// Strategy 留空默认使用 SplitRecursive。Tenant 留空表示不区分租户。
+type ChunkOptions struct {
+	Strategy     SplitStrategy
+	ChunkSize    int
+	ChunkOverlap int
+	Tenant       string // 非空时写入每个分片 Metadata["tenant"]，供按租户过滤检索
+}
+
+// withDefaults 补齐零值字段为默认值
+func (o ChunkOptions) withDefaults() ChunkOptions {
+	if o.ChunkSize <= 0 {
+		o.ChunkSize = defaultChunkSize
+	}
+	if o.ChunkOverlap <= 0 {
+		o.ChunkOverlap = defaultChunkOverlap
+	}
+	if o.Strategy == "" {
+		o.Strategy = SplitRecursive
+	}
+	return o
+}
+
+// Chunk 一个文档切片
+type Chunk struct {
+	Content     string // 切片正文
+	HeadingPath string // 切片所属的标题路径（Markdown # 标题逐级拼接），用于保留上下文
+	Index       int    // 在原文档中的顺序
+}
+
+// SplitText 用递归字符分割器把长文本切成约 chunkSize 字符、重叠 overlap 字符的分片。
+// chunkSize/overlap 传 0 时使用默认值。等价于 Split(text, ChunkOptions{Strategy: SplitRecursive, ...})。
+func SplitText(text string, chunkSize, overlap int) []Chunk {
+	return Split(text, ChunkOptions{Strategy: SplitRecursive, ChunkSize: chunkSize, ChunkOverlap: overlap})
+}
+
+// Split 按 opts.Strategy 把长文本切成分片，并为每个分片标注其所在的标题路径
+func Split(text string, opts ChunkOptions) []Chunk {
+	opts = opts.withDefaults()
+
+	var pieces []string
+	switch opts.Strategy {
+	case SplitFixed:
+		pieces = fixedSplit(text, opts.ChunkSize, opts.ChunkOverlap)
+	case SplitSentence:
+		sentences := splitSentences(text)
+		pieces = mergeWithOverlap(sentences, opts.ChunkSize, opts.ChunkOverlap)
+	default:
+		raw := recursiveSplit(text, splitSeparators, opts.ChunkSize)
+		pieces = mergeWithOverlap(raw, opts.ChunkSize, opts.ChunkOverlap)
+	}
+
+	chunks := make([]Chunk, len(pieces))
+	headingStack := make([]string, 0, 6)
+	for i, content := range pieces {
+		headingStack = applyHeadings(headingStack, content)
+		chunks[i] = Chunk{Content: content, HeadingPath: joinHeadings(headingStack), Index: i}
+	}
+	return chunks
+}
+
+// joinHeadings 把标题栈中的非空层级用 " > " 拼成路径（跳过层级被跳过产生的空位）
+func joinHeadings(stack []string) string {
+	levels := make([]string, 0, len(stack))
+	for _, h := range stack {
+		if h != "" {
+			levels = append(levels, h)
+		}
+	}
+	return strings.Join(levels, " > ")
+}
+
+// recursiveSplit 依次尝试每个分隔符，把超过 chunkSize 的片段继续往下一个分隔符拆分，
+// 直到片段足够小或分隔符用尽。
+func recursiveSplit(text string, separators []string, chunkSize int) []string {
+	if len([]rune(text)) <= chunkSize || len(separators) == 0 {
+		return []string{text}
+	}
+
+	sep := separators[0]
+	parts := strings.Split(text, sep)
+
+	var result []string
+	for _, part := range parts {
+		if part == "" {
+			continue
+		}
+		if len([]rune(part)) > chunkSize {
+			result = append(result, recursiveSplit(part, separators[1:], chunkSize)...)
+		} else {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
+// fixedSplit 不考虑语义边界，按固定窗口长度切分，窗口之间保留 overlap 个字符重叠，
+// 是最简单、最可预测的切分方式，适合格式高度规整、语义边界不重要的文本。
+func fixedSplit(text string, chunkSize, overlap int) []string {
+	runes := []rune(text)
+	if len(runes) == 0 {
+		return nil
+	}
+
+	step := chunkSize - overlap
+	if step <= 0 {
+		step = chunkSize
+	}
+
+	var chunks []string
+	for start := 0; start < len(runes); start += step {
+		end := start + chunkSize
+		if end > len(runes) {
+			end = len(runes)
+		}
+		chunks = append(chunks, string(runes[start:end]))
+		if end == len(runes) {
+			break
+		}
+	}
+	return chunks
+}
+
+// splitSentences 按中英文句末标点把文本切成句子，标点本身保留在句尾
+func splitSentences(text string) []string {
+	var sentences []string
+	var current strings.Builder
+
+	for _, r := range text {
+		current.WriteRune(r)
+		for _, sep := range sentenceSeparators {
+			if strings.HasSuffix(current.String(), sep) {
+				sentences = append(sentences, current.String())
+				current.Reset()
+				break
+			}
+		}
+	}
+	if current.Len() > 0 {
+		sentences = append(sentences, current.String())
+	}
+	return sentences
+}
+
+// mergeWithOverlap 把细碎片段重新合并到接近 chunkSize 的分片，相邻分片之间保留
+// overlap 个字符的重叠，减少切分边界丢失上下文的问题。
+func mergeWithOverlap(pieces []string, chunkSize, overlap int) []string {
+	var chunks []string
+	var current strings.Builder
+
+	flush := func() {
+		if current.Len() > 0 {
+			chunks = append(chunks, current.String())
+		}
+	}
+
+	for _, piece := range pieces {
+		if current.Len() > 0 && len([]rune(current.String()))+len([]rune(piece)) > chunkSize {
+			flush()
+			tail := lastNChars(current.String(), overlap)
+			current.Reset()
+			current.WriteString(tail)
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n")
+		}
+		current.WriteString(piece)
+	}
+	flush()
+
+	return chunks
+}
+
+// lastNChars 返回字符串末尾最多 n 个 rune，用于构造重叠前缀
+func lastNChars(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[len(runes)-n:])
+}
+
+// applyHeadings 扫描片段中的 Markdown 标题行，按标题级别（# 的个数）更新标题栈：
+// 遇到 N 级标题时，栈内比 N 深的标题被丢弃，再把该标题写入第 N 层，使
+// HeadingPath 始终反映"从顶层到当前片段"的完整标题路径。
+func applyHeadings(stack []string, content string) []string {
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		level := 0
+		for level < len(trimmed) && trimmed[level] == '#' {
+			level++
+		}
+		title := strings.TrimSpace(strings.TrimLeft(trimmed, "# "))
+		if title == "" {
+			continue
+		}
+
+		for len(stack) < level {
+			stack = append(stack, "")
+		}
+		stack = stack[:level]
+		stack[level-1] = title
+	}
+	return stack
+}
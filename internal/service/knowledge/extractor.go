@@ -0,0 +1,217 @@
+package knowledge
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Extractor 从上传文件的原始字节中提取纯文本，不同文件类型对应不同实现
+type Extractor interface {
+	Extract(data []byte) (string, error)
+}
+
+// TextExtractor 处理纯文本/Markdown，原样返回
+type TextExtractor struct{}
+
+// Extract 直接按 UTF-8 文本返回
+func (TextExtractor) Extract(data []byte) (string, error) {
+	return string(data), nil
+}
+
+// htmlTagPattern 匹配 HTML 标签，htmlScriptStylePattern 匹配整块 <script>/<style>，
+// 两者都在抽取正文前去除，避免脚本/样式内容混入知识库
+var (
+	htmlScriptStylePattern = regexp.MustCompile(`(?is)<(script|style)[^>]*>.*?</(script|style)>`)
+	htmlTagPattern         = regexp.MustCompile(`(?s)<[^>]+>`)
+	htmlEntityReplacer     = strings.NewReplacer(
+		"&nbsp;", " ", "&amp;", "&", "&lt;", "<", "&gt;", ">", "&quot;", `"`, "&#39;", "'",
+	)
+)
+
+// HTMLExtractor 从 HTML 中剥离标签、脚本和样式，保留纯文本正文。不依赖第三方
+// HTML 解析库，用正则做轻量清洗，足以覆盖知识库场景下结构简单的文章页面。
+type HTMLExtractor struct{}
+
+// Extract 去除 script/style 块和标签，折叠空白后返回正文
+func (HTMLExtractor) Extract(data []byte) (string, error) {
+	text := string(data)
+	text = htmlScriptStylePattern.ReplaceAllString(text, "")
+	text = htmlTagPattern.ReplaceAllString(text, "\n")
+	text = htmlEntityReplacer.Replace(text)
+
+	lines := strings.Split(text, "\n")
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if trimmed := strings.TrimSpace(line); trimmed != "" {
+			kept = append(kept, trimmed)
+		}
+	}
+	return strings.Join(kept, "\n"), nil
+}
+
+// CSVExtractor 把表格转成一行一条记录的文本，列之间用空格拼接、附带表头，
+// 方便切片后仍能作为自然语言片段被检索到。
+type CSVExtractor struct{}
+
+// Extract 解析 CSV，每行记录渲染成 "表头: 值" 的形式
+func (CSVExtractor) Extract(data []byte) (string, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return "", fmt.Errorf("解析 CSV 失败: %w", err)
+	}
+	if len(rows) == 0 {
+		return "", nil
+	}
+
+	header := rows[0]
+	var builder strings.Builder
+	for _, row := range rows[1:] {
+		for i, value := range row {
+			if i > 0 {
+				builder.WriteString("; ")
+			}
+			if i < len(header) {
+				builder.WriteString(header[i])
+				builder.WriteString(": ")
+			}
+			builder.WriteString(value)
+		}
+		builder.WriteString("\n")
+	}
+	return builder.String(), nil
+}
+
+// JSONLExtractor 把每行一个 JSON 对象的 JSONL 文件转成 "key: value" 形式的文本，
+// 无法解析的行原样保留，避免因个别脏数据丢掉整份文件。
+type JSONLExtractor struct{}
+
+// Extract 按行解析 JSON 对象，拼成可读文本
+func (JSONLExtractor) Extract(data []byte) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+
+	var builder strings.Builder
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			builder.WriteString(line)
+			builder.WriteString("\n")
+			continue
+		}
+
+		keys := make([]string, 0, len(record))
+		for k := range record {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for i, k := range keys {
+			if i > 0 {
+				builder.WriteString("; ")
+			}
+			builder.WriteString(fmt.Sprintf("%s: %v", k, record[k]))
+		}
+		builder.WriteString("\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("读取 JSONL 失败: %w", err)
+	}
+	return builder.String(), nil
+}
+
+// pdfStreamPattern 匹配 PDF 内容流对象（stream ... endstream）
+var pdfStreamPattern = regexp.MustCompile(`(?s)stream\r?\n(.*?)endstream`)
+
+// pdfShowTextPattern 匹配内容流中 Tj/TJ 文本展示操作符：分组 1 是 Tj（以及 ' 和 "）
+// 的括号字符串，分组 2 是 TJ 数组的内容
+var pdfShowTextPattern = regexp.MustCompile(`\(((?:[^()\\]|\\.)*)\)\s*(?:Tj|'|")|\[((?:[^\[\]()\\]|\\.|\((?:[^()\\]|\\.)*\))*)\]\s*TJ`)
+
+// pdfStringPattern 匹配 TJ 数组内的每个括号字符串
+var pdfStringPattern = regexp.MustCompile(`\(((?:[^()\\]|\\.)*)\)`)
+
+// PDFExtractor 不依赖第三方库的极简 PDF 文本提取：解压每个 FlateDecode 内容流，
+// 从中抓取 Tj/TJ 文本展示操作符里的字符串拼成正文。无法覆盖加密文档、
+// 扫描版 PDF（图片无文本层）等情况，足以应对常见的纯文本排版文档。
+type PDFExtractor struct{}
+
+// Extract 解压内容流并提取可展示文本
+func (PDFExtractor) Extract(data []byte) (string, error) {
+	var builder strings.Builder
+
+	for _, match := range pdfStreamPattern.FindAllSubmatch(data, -1) {
+		raw := bytes.TrimSpace(match[1])
+
+		decoded, err := inflatePDFStream(raw)
+		if err != nil {
+			// 不是 FlateDecode 流（例如图片、字体），跳过继续处理下一个
+			continue
+		}
+
+		for _, text := range pdfShowTextPattern.FindAllSubmatch(decoded, -1) {
+			if text[2] != nil {
+				for _, s := range pdfStringPattern.FindAllSubmatch(text[2], -1) {
+					builder.Write(unescapePDFString(s[1]))
+				}
+			} else {
+				builder.Write(unescapePDFString(text[1]))
+			}
+			builder.WriteString(" ")
+		}
+		builder.WriteString("\n")
+	}
+
+	text := strings.TrimSpace(builder.String())
+	if text == "" {
+		return "", fmt.Errorf("未能从 PDF 中提取到文本，可能是扫描版或加密文档")
+	}
+	return text, nil
+}
+
+// inflatePDFStream 尝试用 zlib 解压内容流（PDF FlateDecode 使用标准 zlib 封装）
+func inflatePDFStream(raw []byte) ([]byte, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// unescapePDFString 处理 PDF 字符串里的反斜杠转义（\(、\)、\\、\n 等）
+func unescapePDFString(s []byte) []byte {
+	replacer := strings.NewReplacer(`\(`, "(", `\)`, ")", `\\`, `\`, `\n`, "\n", `\r`, "")
+	return []byte(replacer.Replace(string(s)))
+}
+
+// ExtractorForFilename 根据文件名后缀选择对应的 Extractor
+func ExtractorForFilename(filename string) Extractor {
+	lower := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(lower, ".pdf"):
+		return PDFExtractor{}
+	case strings.HasSuffix(lower, ".html"), strings.HasSuffix(lower, ".htm"):
+		return HTMLExtractor{}
+	case strings.HasSuffix(lower, ".csv"):
+		return CSVExtractor{}
+	case strings.HasSuffix(lower, ".jsonl"), strings.HasSuffix(lower, ".ndjson"):
+		return JSONLExtractor{}
+	default:
+		return TextExtractor{}
+	}
+}
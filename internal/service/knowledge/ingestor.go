@@ -0,0 +1,152 @@
+package knowledge
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/supportbot/supportbot-go/internal/client"
+	"github.com/supportbot/supportbot-go/internal/vectorstore"
+	"go.uber.org/zap"
+)
+
+// Ingestor 把长文档切片、批量向量化，产出可以直接写入 VectorStore 的 Document 列表
+type Ingestor struct {
+	embeddingClient *client.EmbeddingClient
+	httpClient      *http.Client
+	chunkSize       int
+	chunkOverlap    int
+	logger          *zap.Logger
+}
+
+// NewIngestor 创建 Ingestor，chunkSize/chunkOverlap 传 0 使用默认值（500/50）
+func NewIngestor(embeddingClient *client.EmbeddingClient, chunkSize, chunkOverlap int, logger *zap.Logger) *Ingestor {
+	return &Ingestor{
+		embeddingClient: embeddingClient,
+		httpClient:      &http.Client{},
+		chunkSize:       chunkSize,
+		chunkOverlap:    chunkOverlap,
+		logger:          logger,
+	}
+}
+
+// IngestFile 提取文件文本、切片、批量向量化，返回可直接写入 VectorStore 的分片文档。
+// sourceID 作为 parent_id，同时也是每个分片 Document.ID 的前缀。
+func (ing *Ingestor) IngestFile(sourceID, filename string, data []byte) ([]vectorstore.Document, error) {
+	text, err := ExtractorForFilename(filename).Extract(data)
+	if err != nil {
+		return nil, fmt.Errorf("提取文本失败: %w", err)
+	}
+
+	return ing.ingestText(sourceID, filename, text, ing.defaultOptions())
+}
+
+// IngestURL 抓取 URL 内容并按文本处理、切片、向量化
+func (ing *Ingestor) IngestURL(sourceID, url string) ([]vectorstore.Document, error) {
+	resp, err := ing.httpClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("抓取 URL 失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("抓取 URL 返回非 200 状态: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取 URL 响应失败: %w", err)
+	}
+
+	return ing.ingestText(sourceID, url, string(body), ing.defaultOptions())
+}
+
+// IngestPath 从本地磁盘读取单个文件，按 opts 指定的分片策略切片、向量化。
+// sourceID 作为 parent_id，path 作为 source_path 写入每个分片的 Metadata。
+func (ing *Ingestor) IngestPath(sourceID, path string, opts ChunkOptions) ([]vectorstore.Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+
+	text, err := ExtractorForFilename(path).Extract(data)
+	if err != nil {
+		return nil, fmt.Errorf("提取文本失败: %w", err)
+	}
+
+	return ing.ingestText(sourceID, path, text, opts)
+}
+
+// defaultOptions 返回 Ingestor 构造时传入的 chunkSize/chunkOverlap，分片策略固定为递归分割
+func (ing *Ingestor) defaultOptions() ChunkOptions {
+	return ChunkOptions{Strategy: SplitRecursive, ChunkSize: ing.chunkSize, ChunkOverlap: ing.chunkOverlap}
+}
+
+// ingestText 切片 + 批量向量化 + 组装 Document，doc_id/chunk_index/source_path/
+// heading_path 写入 Metadata；parent_id/source 沿用旧字段名以兼容既有调用方
+// （DeleteBySource 按 parent_id 批量删除、GroupByParent 按 parent_id 聚合）。
+func (ing *Ingestor) ingestText(sourceID, source string, text string, opts ChunkOptions) ([]vectorstore.Document, error) {
+	chunks := Split(text, opts)
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("文档内容为空，无法切片")
+	}
+
+	ing.logger.Info("文档切片完成",
+		zap.String("source", source),
+		zap.String("strategy", string(opts.Strategy)),
+		zap.Int("chunks", len(chunks)))
+
+	texts := make([]string, len(chunks))
+	for i, c := range chunks {
+		texts[i] = c.Content
+	}
+
+	vectors, err := ing.embeddingClient.GetEmbeddings(texts)
+	if err != nil {
+		return nil, fmt.Errorf("批量向量化失败: %w", err)
+	}
+
+	docs := make([]vectorstore.Document, len(chunks))
+	for i, c := range chunks {
+		metadata := map[string]string{
+			"parent_id":    sourceID,
+			"doc_id":       sourceID,
+			"chunk_index":  strconv.Itoa(c.Index),
+			"source":       source,
+			"source_path":  source,
+			"heading_path": c.HeadingPath,
+		}
+		if opts.Tenant != "" {
+			metadata["tenant"] = opts.Tenant
+		}
+		docs[i] = vectorstore.Document{
+			ID:       fmt.Sprintf("%s#%d", sourceID, c.Index),
+			Content:  c.Content,
+			Vector:   vectors[i],
+			Metadata: metadata,
+		}
+	}
+
+	return docs, nil
+}
+
+// ListFiles 递归列出 dir 下的所有普通文件路径，用于 IngestDirectory 遍历目录
+func ListFiles(dir string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("遍历目录失败: %w", err)
+	}
+	return paths, nil
+}
@@ -1,30 +1,228 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/supportbot/supportbot-go/internal/client"
+	"github.com/supportbot/supportbot-go/internal/service/knowledge"
 	"github.com/supportbot/supportbot-go/internal/vectorstore"
 	"go.uber.org/zap"
 )
 
+// hybridFanOut 混合检索时每一路（向量/BM25）召回的候选数量，融合排序后再截取 topK
+const hybridFanOut = 20
+
+// rerankFanOut 配置了 Reranker 时，向量检索先召回的候选数量，交叉编码器精排后再截取 topK
+const rerankFanOut = 50
+
+// docCacheTTL 文档元数据在 Redis 缓存中的有效期
+const docCacheTTL = 10 * time.Minute
+
+// docCacheKeyPrefix Redis 中缓存文档的 key 前缀
+const docCacheKeyPrefix = "knowledge:doc:"
+
+// SearchMode 检索策略：纯向量、纯词法（BM25）或两者融合
+type SearchMode string
+
+const (
+	SearchModeDense   SearchMode = "dense"
+	SearchModeLexical SearchMode = "lexical"
+	SearchModeHybrid  SearchMode = "hybrid"
+)
+
+// parseSearchMode 把 config.RetrievalConfig.Mode 中沿用的 "vector"/"bm25"/"hybrid"
+// 字符串映射为 SearchMode，未识别的值回退到纯向量检索
+func parseSearchMode(mode string) SearchMode {
+	switch mode {
+	case "bm25":
+		return SearchModeLexical
+	case "hybrid":
+		return SearchModeHybrid
+	default:
+		return SearchModeDense
+	}
+}
+
 // KnowledgeService 知识库服务
 type KnowledgeService struct {
 	embeddingClient *client.EmbeddingClient
-	vectorStore     *vectorstore.MemoryVectorStore
+	vectorStore     vectorstore.VectorStore
+	bm25Index       *vectorstore.BM25Index
+	retrievalMode   SearchMode
+	reranker        Reranker            // 可选的二次精排阶段，nil 表示不启用
+	ingestor        *knowledge.Ingestor // 可选，装配后 IngestFile/IngestDirectory 才可用
+	metadataCache   *redis.Client       // 可选，装配后 ExpandWithNeighbors 按 ID 回查相邻分片时会先查 Redis 缓存再回源
 	logger          *zap.Logger
 }
 
-// NewKnowledgeService 创建知识库服务
-func NewKnowledgeService(embeddingClient *client.EmbeddingClient, vectorStore *vectorstore.MemoryVectorStore, logger *zap.Logger) *KnowledgeService {
+// NewKnowledgeService 创建知识库服务。vectorStore 可以是 MemoryVectorStore、
+// BoltVectorStore 或 QdrantVectorStore 中的任意一种，上层逻辑无需感知具体后端。
+// retrievalMode 取值为 "vector"、"bm25" 或 "hybrid"，决定 SearchKnowledge 默认
+// 使用纯向量、纯 BM25 还是两者融合的检索策略。
+func NewKnowledgeService(embeddingClient *client.EmbeddingClient, vectorStore vectorstore.VectorStore, retrievalMode string, logger *zap.Logger) *KnowledgeService {
 	return &KnowledgeService{
 		embeddingClient: embeddingClient,
 		vectorStore:     vectorStore,
+		bm25Index:       vectorstore.NewBM25Index(),
+		retrievalMode:   parseSearchMode(retrievalMode),
 		logger:          logger,
 	}
 }
 
+// SetReranker 为知识库服务装配一个可选的二次精排阶段。配置后 SearchKnowledge 在
+// 纯向量模式下会先召回 rerankFanOut 条候选，再由 reranker 精排截取到 topK；
+// 传入 nil 等效于关闭重排，回到原本的检索结果。
+func (s *KnowledgeService) SetReranker(reranker Reranker) {
+	s.reranker = reranker
+}
+
+// SetIngestor 为知识库服务装配长文档摄取器，装配后才能调用 IngestFile/IngestDirectory
+func (s *KnowledgeService) SetIngestor(ingestor *knowledge.Ingestor) {
+	s.ingestor = ingestor
+}
+
+// SetMetadataCache 为知识库服务装配一个可选的 Redis 元数据缓存。装配后，
+// ExpandWithNeighbors 在按 ID 回查相邻分片时会先查缓存，未命中才回源到
+// vectorStore 并写回缓存，减少 HNSW/Bolt 后端上重复的按 ID 查找；传入 nil
+// 等效于关闭缓存，回到直接查询 vectorStore 的行为。
+func (s *KnowledgeService) SetMetadataCache(redisClient *redis.Client) {
+	s.metadataCache = redisClient
+}
+
+// getDocumentCached 按 ID 获取文档，优先命中 Redis 缓存；未装配 metadataCache
+// 时直接透传给 vectorStore.GetDocument。
+func (s *KnowledgeService) getDocumentCached(id string) (*vectorstore.Document, error) {
+	if s.metadataCache == nil {
+		return s.vectorStore.GetDocument(id)
+	}
+
+	ctx := context.Background()
+	cacheKey := docCacheKeyPrefix + id
+	if data, err := s.metadataCache.Get(ctx, cacheKey).Result(); err == nil {
+		var doc vectorstore.Document
+		if err := json.Unmarshal([]byte(data), &doc); err == nil {
+			return &doc, nil
+		}
+	}
+
+	doc, err := s.vectorStore.GetDocument(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err := json.Marshal(doc); err == nil {
+		if err := s.metadataCache.Set(ctx, cacheKey, data, docCacheTTL).Err(); err != nil {
+			s.logger.Warn("写入文档元数据缓存失败", zap.String("id", id), zap.Error(err))
+		}
+	}
+	return doc, nil
+}
+
+// IngestFile 读取本地磁盘上的单个文件，按 opts 指定的策略切片、向量化后写入知识库。
+// sourceID 作为分片的 parent_id/doc_id，用于之后按来源批量删除（DeleteBySource）。
+func (s *KnowledgeService) IngestFile(sourceID, path string, opts knowledge.ChunkOptions) (int, error) {
+	if s.ingestor == nil {
+		return 0, fmt.Errorf("未装配 Ingestor，请先调用 SetIngestor")
+	}
+
+	docs, err := s.ingestor.IngestPath(sourceID, path, opts)
+	if err != nil {
+		return 0, fmt.Errorf("摄取文件失败: %w", err)
+	}
+
+	if err := s.AddKnowledgeChunks(docs); err != nil {
+		return 0, err
+	}
+	return len(docs), nil
+}
+
+// IngestDirectory 递归遍历 dir 下的所有文件逐个调用 IngestFile，sourceID 为每个文件的
+// parent_id 前缀加上相对路径，便于区分同一目录下的多个文件。单个文件失败不会中断整体
+// 摄取，失败的文件会被记录到日志并计入返回的 error（聚合成单条汇总信息）。
+func (s *KnowledgeService) IngestDirectory(dir string, opts knowledge.ChunkOptions) (int, error) {
+	paths, err := knowledge.ListFiles(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	totalChunks := 0
+	var failed []string
+	for _, path := range paths {
+		chunkCount, err := s.IngestFile(path, path, opts)
+		if err != nil {
+			s.logger.Error("摄取目录中的文件失败", zap.String("path", path), zap.Error(err))
+			failed = append(failed, path)
+			continue
+		}
+		totalChunks += chunkCount
+	}
+
+	s.logger.Info("目录摄取完成",
+		zap.String("dir", dir),
+		zap.Int("files", len(paths)),
+		zap.Int("chunks", totalChunks),
+		zap.Int("failed", len(failed)))
+
+	if len(failed) > 0 {
+		return totalChunks, fmt.Errorf("%d/%d 个文件摄取失败: %v", len(failed), len(paths), failed)
+	}
+	return totalChunks, nil
+}
+
+// ExpandWithNeighbors 实现 small-to-big 检索：对每条命中的分片，按 parent_id +
+// chunk_index 把前后 radius 个相邻分片一并取出补充进结果，在保留精确匹配排序的
+// 同时让 BuildContext 拿到更完整的上下文。非分片文档（没有 parent_id/chunk_index）
+// 原样保留。建议在 SearchKnowledge 之后、BuildContext 之前调用。
+func (s *KnowledgeService) ExpandWithNeighbors(results []vectorstore.SearchResult, radius int) []vectorstore.SearchResult {
+	if radius <= 0 {
+		return results
+	}
+
+	seen := make(map[string]bool, len(results))
+	expanded := make([]vectorstore.SearchResult, 0, len(results))
+	for _, r := range results {
+		seen[r.Document.ID] = true
+		expanded = append(expanded, r)
+	}
+
+	for _, r := range results {
+		parentID := r.Document.Metadata["parent_id"]
+		indexStr := r.Document.Metadata["chunk_index"]
+		if parentID == "" || indexStr == "" {
+			continue
+		}
+		index, err := strconv.Atoi(indexStr)
+		if err != nil {
+			continue
+		}
+
+		for offset := -radius; offset <= radius; offset++ {
+			if offset == 0 {
+				continue
+			}
+			neighborID := fmt.Sprintf("%s#%d", parentID, index+offset)
+			if seen[neighborID] {
+				continue
+			}
+			doc, err := s.getDocumentCached(neighborID)
+			if err != nil {
+				continue
+			}
+			seen[neighborID] = true
+			expanded = append(expanded, vectorstore.SearchResult{Document: *doc, Score: r.Score})
+		}
+	}
+
+	return GroupByParent(expanded)
+}
+
 // AddKnowledge 添加知识（文本 → 向量化 → 存储）
 func (s *KnowledgeService) AddKnowledge(id, content string, metadata map[string]string) error {
 	s.logger.Info("添加知识", zap.String("id", id), zap.Int("length", len(content)))
@@ -47,6 +245,8 @@ func (s *KnowledgeService) AddKnowledge(id, content string, metadata map[string]
 		return fmt.Errorf("存储失败: %w", err)
 	}
 
+	s.bm25Index.AddDocument(id, content)
+
 	return nil
 }
 
@@ -80,20 +280,155 @@ func (s *KnowledgeService) AddKnowledgeBatch(items []KnowledgeItem) error {
 		return fmt.Errorf("批量存储失败: %w", err)
 	}
 
+	for _, item := range items {
+		s.bm25Index.AddDocument(item.ID, item.Content)
+	}
+
+	return nil
+}
+
+// AddKnowledgeChunks 写入由 knowledge.Ingestor 切片并向量化好的文档分片。
+// 每个分片的 Metadata["parent_id"] 会随文档本身持久化到 vectorStore，
+// DeleteBySource 据此按来源批量查找分片，不在进程内额外维护映射。
+func (s *KnowledgeService) AddKnowledgeChunks(docs []vectorstore.Document) error {
+	if len(docs) == 0 {
+		return fmt.Errorf("没有可写入的分片")
+	}
+
+	s.logger.Info("写入知识分片", zap.Int("count", len(docs)))
+
+	if err := s.vectorStore.AddDocuments(docs); err != nil {
+		return fmt.Errorf("批量存储分片失败: %w", err)
+	}
+
+	for _, doc := range docs {
+		s.bm25Index.AddDocument(doc.ID, doc.Content)
+	}
+
 	return nil
 }
 
-// SearchKnowledge 检索知识（查询 → 向量化 → 相似度搜索）
+// DeleteBySource 删除指定 parent_id 下的所有分片（向量存储与 BM25 索引同步清理）。
+// 分片集合每次都从 vectorStore 按 parent_id 元数据现查，而不是依赖进程内存映射，
+// 这样 bolt/hnsw/qdrant 等持久化后端在服务重启后依然能正确定位并删除。
+func (s *KnowledgeService) DeleteBySource(parentID string) (int, error) {
+	ids, err := s.vectorStore.ListIDsByMetadata(vectorstore.MetadataFilter{"parent_id": parentID})
+	if err != nil {
+		return 0, fmt.Errorf("查询来源分片失败: %w", err)
+	}
+	if len(ids) == 0 {
+		return 0, fmt.Errorf("未找到来源: %s", parentID)
+	}
+
+	deleted := 0
+	for _, id := range ids {
+		if err := s.vectorStore.DeleteDocument(id); err != nil {
+			s.logger.Error("删除分片失败", zap.String("id", id), zap.Error(err))
+			continue
+		}
+		s.bm25Index.Remove(id)
+		deleted++
+	}
+
+	s.logger.Info("按来源删除分片完成", zap.String("parentId", parentID), zap.Int("count", deleted))
+	return deleted, nil
+}
+
+// GroupByParent 把检索结果按 parent_id 重新排列：同一来源的分片聚在一起，
+// 分组顺序按组内最高分排序，组内分片保持原有相对顺序。非分片文档（没有 parent_id）
+// 各自单独成组。用于长文档场景下让同一来源的上下文在展示时连续出现。
+func GroupByParent(results []vectorstore.SearchResult) []vectorstore.SearchResult {
+	if len(results) == 0 {
+		return results
+	}
+
+	type group struct {
+		key      string
+		items    []vectorstore.SearchResult
+		bestRank float64
+	}
+
+	groups := make(map[string]*group)
+	order := make([]string, 0)
+
+	for _, r := range results {
+		key := r.Document.Metadata["parent_id"]
+		if key == "" {
+			key = r.Document.ID
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &group{key: key, bestRank: r.Score}
+			groups[key] = g
+			order = append(order, key)
+		}
+		g.items = append(g.items, r)
+		if r.Score > g.bestRank {
+			g.bestRank = r.Score
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return groups[order[i]].bestRank > groups[order[j]].bestRank
+	})
+
+	grouped := make([]vectorstore.SearchResult, 0, len(results))
+	for _, key := range order {
+		grouped = append(grouped, groups[key].items...)
+	}
+	return grouped
+}
+
+// SearchKnowledge 检索知识，按 retrievalMode 选择纯向量、纯 BM25 或两者融合的策略
 func (s *KnowledgeService) SearchKnowledge(query string, topK int, minScore float64) ([]vectorstore.SearchResult, error) {
-	s.logger.Info("检索知识", zap.String("query", query), zap.Int("topK", topK))
+	s.logger.Info("检索知识",
+		zap.String("query", query),
+		zap.Int("topK", topK),
+		zap.String("mode", string(s.retrievalMode)))
+
+	switch s.retrievalMode {
+	case SearchModeLexical:
+		return s.searchBM25(query, topK), nil
+	case SearchModeHybrid:
+		return s.searchHybrid(query, topK, 1.0, 1.0)
+	default:
+		if s.reranker != nil {
+			return s.searchVectorReranked(query, topK, minScore)
+		}
+		return s.searchVector(query, topK, minScore)
+	}
+}
+
+// searchVectorReranked 先用向量检索便宜地召回 rerankFanOut 条候选，再交给
+// reranker 做交叉编码精排截取到 topK，兼顾召回成本和排序质量。
+func (s *KnowledgeService) searchVectorReranked(query string, topK int, minScore float64) ([]vectorstore.SearchResult, error) {
+	candidates, err := s.searchVector(query, rerankFanOut, minScore)
+	if err != nil {
+		return nil, err
+	}
+	return s.reranker.Rerank(query, candidates, topK)
+}
+
+// SearchKnowledgeHybrid 强制使用向量 + BM25 融合检索，并允许按 vectorWeight/
+// bm25Weight 为两路结果加权（各自默认 1.0），用于让调用方按查询特征（例如命中
+// 明显是 SKU/订单号）临时偏向某一路，而不必整体切换 retrievalMode。
+func (s *KnowledgeService) SearchKnowledgeHybrid(query string, topK int, vectorWeight, bm25Weight float64) ([]vectorstore.SearchResult, error) {
+	s.logger.Info("混合检索知识",
+		zap.String("query", query),
+		zap.Int("topK", topK),
+		zap.Float64("vectorWeight", vectorWeight),
+		zap.Float64("bm25Weight", bm25Weight))
 
-	// 1. 查询向量化
+	return s.searchHybrid(query, topK, vectorWeight, bm25Weight)
+}
+
+// searchVector 纯向量检索：查询向量化后做余弦相似度搜索
+func (s *KnowledgeService) searchVector(query string, topK int, minScore float64) ([]vectorstore.SearchResult, error) {
 	queryVector, err := s.embeddingClient.GetQueryEmbedding(query)
 	if err != nil {
 		return nil, fmt.Errorf("查询向量化失败: %w", err)
 	}
 
-	// 2. 向量检索
 	results, err := s.vectorStore.Search(queryVector, topK, minScore)
 	if err != nil {
 		return nil, fmt.Errorf("向量检索失败: %w", err)
@@ -102,22 +437,189 @@ func (s *KnowledgeService) SearchKnowledge(query string, topK int, minScore floa
 	return results, nil
 }
 
-// BuildContext 构建 RAG 上下文（将检索结果组合成文本）
-func (s *KnowledgeService) BuildContext(results []vectorstore.SearchResult) string {
+// SearchKnowledgeWithFilter 与 SearchKnowledge 相同，但只在满足 filter 的文档中检索，
+// 例如 {"category": "退货政策"}。过滤在向量存储的候选扩展阶段完成，
+// 仅对向量检索路径生效（BM25/混合检索见后续需求再扩展）。
+func (s *KnowledgeService) SearchKnowledgeWithFilter(query string, topK int, minScore float64, filter vectorstore.MetadataFilter) ([]vectorstore.SearchResult, error) {
+	s.logger.Info("按元数据过滤检索知识",
+		zap.String("query", query),
+		zap.Int("topK", topK),
+		zap.Any("filter", filter))
+
+	queryVector, err := s.embeddingClient.GetQueryEmbedding(query)
+	if err != nil {
+		return nil, fmt.Errorf("查询向量化失败: %w", err)
+	}
+
+	results, err := s.vectorStore.SearchWithFilter(queryVector, topK, minScore, filter)
+	if err != nil {
+		return nil, fmt.Errorf("向量检索失败: %w", err)
+	}
+
+	return results, nil
+}
+
+// searchBM25 纯词法检索，适合 SKU、错误码等需要精确命中的查询
+func (s *KnowledgeService) searchBM25(query string, topK int) []vectorstore.SearchResult {
+	bm25Results := s.bm25Index.Search(query, topK)
+
+	results := make([]vectorstore.SearchResult, 0, len(bm25Results))
+	for _, r := range bm25Results {
+		doc, err := s.vectorStore.GetDocument(r.DocID)
+		if err != nil {
+			continue
+		}
+		results = append(results, vectorstore.SearchResult{Document: *doc, Score: r.Score})
+	}
+	return results
+}
+
+// searchHybrid 并行跑向量检索与 BM25 检索各自召回 hybridFanOut 条，再用 Reciprocal
+// Rank Fusion（k=60）按 vectorWeight/bm25Weight 加权融合排序后截取 Top-K，
+// 兼顾语义召回和精确词命中。
+func (s *KnowledgeService) searchHybrid(query string, topK int, vectorWeight, bm25Weight float64) ([]vectorstore.SearchResult, error) {
+	vectorResults, err := s.searchVector(query, hybridFanOut, 0)
+	if err != nil {
+		return nil, err
+	}
+	bm25Results := s.bm25Index.Search(query, hybridFanOut)
+
+	vectorRanking := make([]string, len(vectorResults))
+	for i, r := range vectorResults {
+		vectorRanking[i] = r.Document.ID
+	}
+	bm25Ranking := make([]string, len(bm25Results))
+	for i, r := range bm25Results {
+		bm25Ranking[i] = r.DocID
+	}
+
+	s.logger.Info("混合检索各路召回",
+		zap.Strings("vectorRanking", vectorRanking),
+		zap.Strings("bm25Ranking", bm25Ranking))
+
+	fused := vectorstore.WeightedReciprocalRankFusion([][]string{vectorRanking, bm25Ranking}, []float64{vectorWeight, bm25Weight})
+
+	type fusedDoc struct {
+		id    string
+		score float64
+	}
+	docs := make([]fusedDoc, 0, len(fused))
+	for id, score := range fused {
+		docs = append(docs, fusedDoc{id: id, score: score})
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].score > docs[j].score })
+	if len(docs) > topK {
+		docs = docs[:topK]
+	}
+
+	results := make([]vectorstore.SearchResult, 0, len(docs))
+	for _, d := range docs {
+		doc, err := s.vectorStore.GetDocument(d.id)
+		if err != nil {
+			continue
+		}
+		results = append(results, vectorstore.SearchResult{Document: *doc, Score: d.score})
+	}
+	return results, nil
+}
+
+// defaultMaxContextTokens BudgetOptions.MaxTokens 留空时的默认预算
+const defaultMaxContextTokens = 1500
+
+// defaultDedupThreshold BudgetOptions.DedupThreshold 留空时的默认去重阈值：
+// 两个片段的余弦相似度达到此值即视为重复，只保留分数更高的一个
+const defaultDedupThreshold = 0.92
+
+// TokenCounter 估算一段文本消耗的 token 数，用于 BuildContext 按预算裁剪上下文。
+// 和 vectorstore.Tokenizer（为 BM25 切词用）不是一回事：这里只关心数量，不关心
+// 切出来的具体词元，调用方可以换成真正的 BPE 计数实现（如 tiktoken 的 cl100k）。
+type TokenCounter func(text string) int
+
+// approxTokenCount 默认的 TokenCounter 实现：按"字符数/4"估算，不区分中英文。
+// 对中文会偏保守（实际 token/字符比通常更高），但胜在零依赖、零编码开销。
+func approxTokenCount(text string) int {
+	return len([]rune(text))/4 + 1
+}
+
+// BudgetOptions 控制 BuildContext 如何在有限的上下文预算内挑选检索结果。
+// 字段留零值时由 withDefaults 补齐。
+type BudgetOptions struct {
+	MaxTokens      int          // 上下文总 token 预算，默认 defaultMaxContextTokens
+	TokenCounter   TokenCounter // token 计数实现，默认 approxTokenCount
+	DedupThreshold float64      // 去重相似度阈值，默认 defaultDedupThreshold
+}
+
+// withDefaults 补齐零值字段为默认值
+func (o BudgetOptions) withDefaults() BudgetOptions {
+	if o.MaxTokens <= 0 {
+		o.MaxTokens = defaultMaxContextTokens
+	}
+	if o.TokenCounter == nil {
+		o.TokenCounter = approxTokenCount
+	}
+	if o.DedupThreshold <= 0 {
+		o.DedupThreshold = defaultDedupThreshold
+	}
+	return o
+}
+
+// Citation 把 BuildContext 生成的引用标记（如 "[1]"）映射回来源文档，
+// 供下游渲染"资料来源"链接时使用
+type Citation struct {
+	Marker string  // 形如 "[1]"，与上下文正文中的标记一一对应
+	DocID  string  // 对应 Document.ID
+	Score  float64 // 该片段的检索相似度
+}
+
+// BuildContext 构建 RAG 上下文：按相似度从高到低贪心装入片段，用 opts.TokenCounter
+// 累计 token 数，装入后会超出 opts.MaxTokens 预算的片段跳过（其后更短的片段仍可能装入）；
+// 遇到和已选片段余弦相似度达到 opts.DedupThreshold 的片段（近似重复内容）直接丢弃，不占用预算。每个选中的
+// 片段附带稳定的引用标记（[1]、[2]……），通过返回的 Citations 映射回 Document.ID，
+// 便于上层渲染来源链接。
+func (s *KnowledgeService) BuildContext(results []vectorstore.SearchResult, opts BudgetOptions) (string, []Citation) {
 	if len(results) == 0 {
-		return "未找到相关知识"
+		return "未找到相关知识", nil
 	}
+	opts = opts.withDefaults()
 
 	var builder strings.Builder
 	builder.WriteString("参考知识库：\n\n")
 
-	for i, result := range results {
-		builder.WriteString(fmt.Sprintf("【知识片段 %d】(相似度: %.2f)\n", i+1, result.Score))
+	var selected []vectorstore.SearchResult
+	var citations []Citation
+	usedTokens := 0
+
+	for _, result := range results {
+		if isNearDuplicate(result, selected, opts.DedupThreshold) {
+			continue
+		}
+
+		tokens := opts.TokenCounter(result.Document.Content)
+		if usedTokens+tokens > opts.MaxTokens {
+			continue
+		}
+
+		marker := fmt.Sprintf("[%d]", len(selected)+1)
+		builder.WriteString(fmt.Sprintf("%s (相似度: %.2f)\n", marker, result.Score))
 		builder.WriteString(result.Document.Content)
 		builder.WriteString("\n\n")
+
+		citations = append(citations, Citation{Marker: marker, DocID: result.Document.ID, Score: result.Score})
+		selected = append(selected, result)
+		usedTokens += tokens
 	}
 
-	return builder.String()
+	return builder.String(), citations
+}
+
+// isNearDuplicate 判断 candidate 是否与 selected 中任意片段的余弦相似度达到 threshold
+func isNearDuplicate(candidate vectorstore.SearchResult, selected []vectorstore.SearchResult, threshold float64) bool {
+	for _, s := range selected {
+		if vectorstore.CosineSimilarity(candidate.Document.Vector, s.Document.Vector) >= threshold {
+			return true
+		}
+	}
+	return false
 }
 
 // KnowledgeItem 知识条目
@@ -192,8 +694,8 @@ func (s *KnowledgeService) InitDefaultKnowledge() error {
 			ID:      "promotion-double11",
 			Content: "双11大促活动：11月1日-11日全场5折起，每日10点、20点整点秒杀。前1000名下单用户赠送50元无门槛券。购物满500元抽奖，最高可得iPhone 15。活动商品不支持退换货。",
 			Metadata: map[string]string{
-				"category": "促销活动",
-				"source":   "活动页面",
+				"category":   "促销活动",
+				"source":     "活动页面",
 				"valid_date": "2024-11-01 至 2024-11-11",
 			},
 		},
@@ -201,4 +703,3 @@ func (s *KnowledgeService) InitDefaultKnowledge() error {
 
 	return s.AddKnowledgeBatch(knowledgeBase)
 }
-
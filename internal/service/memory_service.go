@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/supportbot/supportbot-go/internal/client"
+	"go.uber.org/zap"
+)
+
+// memoryStreamKeyPrefix / memorySummaryKeyPrefix 对话记忆在 Redis 中的 key 前缀：
+// 原始轮次存成按用户分区的 Stream，滚动摘要单独存成一个字符串
+const (
+	memoryStreamKeyPrefix  = "chat_memory:"
+	memorySummaryKeyPrefix = "chat_summary:"
+)
+
+// memoryTTL 对话记忆（Stream + 摘要）在 Redis 中的过期时间
+const memoryTTL = 24 * time.Hour
+
+// memoryStreamMaxLen Stream 的近似上限（XADD MAXLEN ~），防止单个用户的记忆无限增长；
+// 真正的窗口裁剪由 memoryWindowTokenBudget 触发的摘要来完成，这里只是兜底
+const memoryStreamMaxLen = 200
+
+// memoryWindowTokenBudget 窗口内轮次总 token 数超过该值时，后台触发一次滚动摘要
+const memoryWindowTokenBudget = 2000
+
+// memorySummarizeBatch 每次摘要归并最老的这么多轮，归并后从 Stream 中删除
+const memorySummarizeBatch = 10
+
+// defaultMemoryBudgetTokens BuildContext 的 budget 留空（<=0）时使用的默认预算
+const defaultMemoryBudgetTokens = 800
+
+// memorySummarizeSystemPrompt 滚动摘要调用 LLM 时使用的系统提示词
+const memorySummarizeSystemPrompt = "你是对话记忆压缩助手，负责把历史摘要和新增对话合并成更精炼的滚动摘要，保留关键信息（订单号、用户诉求、已给出的结论），剔除寒暄与重复内容。"
+
+// MemoryTurn 一轮结构化的对话记忆
+type MemoryTurn struct {
+	Role    string `json:"role"` // user 或 assistant
+	Content string `json:"content"`
+	Ts      int64  `json:"ts"`     // Unix 毫秒时间戳
+	Tokens  int    `json:"tokens"` // 由 TokenCounter 估算
+}
+
+// memoryEntry 一条 Stream 记录及其 ID，删除已被摘要吸收的轮次时需要用到 ID
+type memoryEntry struct {
+	id   string
+	turn MemoryTurn
+}
+
+// MemoryService 按用户维护滚动窗口的对话记忆：近期轮次存 Redis Stream，超出
+// memoryWindowTokenBudget 时后台把最老的一批轮次压缩进 chat_summary:<userId>，
+// BuildContext 据此拼出 "摘要 + 最近轮次" 且不超过调用方给定的 token 预算，
+// 供 ClassifierService 和 assistant/general-chat/knowledge-rag 等下游服务复用，
+// 避免像之前那样直接 LRANGE 原始问题、丢失回复且随时间无限增长。
+type MemoryService struct {
+	redisClient  *redis.Client
+	llmClient    client.LLMProvider
+	tokenCounter TokenCounter
+	logger       *zap.Logger
+}
+
+// NewMemoryService 创建对话记忆服务
+func NewMemoryService(redisClient *redis.Client, llmClient client.LLMProvider, logger *zap.Logger) *MemoryService {
+	return &MemoryService{
+		redisClient:  redisClient,
+		llmClient:    llmClient,
+		tokenCounter: approxTokenCount,
+		logger:       logger,
+	}
+}
+
+func memoryStreamKey(userID int64) string {
+	return fmt.Sprintf("%s%d", memoryStreamKeyPrefix, userID)
+}
+
+func memorySummaryKey(userID int64) string {
+	return fmt.Sprintf("%s%d", memorySummaryKeyPrefix, userID)
+}
+
+// AppendTurn 记录一轮对话。写入后如果窗口内总 token 数超过 memoryWindowTokenBudget，
+// 异步触发一次滚动摘要，调用方不必等待摘要完成。
+func (s *MemoryService) AppendTurn(userID int64, role, content string) error {
+	ctx := context.Background()
+	turn := MemoryTurn{
+		Role:    role,
+		Content: content,
+		Ts:      time.Now().UnixMilli(),
+		Tokens:  s.tokenCounter(content),
+	}
+
+	key := memoryStreamKey(userID)
+	if err := s.redisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: key,
+		MaxLen: memoryStreamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{
+			"role":    turn.Role,
+			"content": turn.Content,
+			"ts":      turn.Ts,
+			"tokens":  turn.Tokens,
+		},
+	}).Err(); err != nil {
+		return fmt.Errorf("写入对话记忆失败: %w", err)
+	}
+	s.redisClient.Expire(ctx, key, memoryTTL)
+
+	go s.summarizeIfOverflowing(userID)
+
+	return nil
+}
+
+// BuildContext 拼出 "历史摘要 + 最近轮次" 的对话上下文，总 token 数不超过 budget
+// （<=0 时使用 defaultMemoryBudgetTokens）。没有任何记忆时返回空字符串。
+func (s *MemoryService) BuildContext(userID int64, budget int) (string, error) {
+	if budget <= 0 {
+		budget = defaultMemoryBudgetTokens
+	}
+	ctx := context.Background()
+
+	var builder strings.Builder
+	usedTokens := 0
+
+	summary, err := s.redisClient.Get(ctx, memorySummaryKey(userID)).Result()
+	if err != nil && err != redis.Nil {
+		return "", fmt.Errorf("读取历史摘要失败: %w", err)
+	}
+	if summary != "" {
+		builder.WriteString("历史摘要：" + summary + "\n\n")
+		usedTokens += s.tokenCounter(summary)
+	}
+
+	entries, err := s.readEntries(ctx, userID)
+	if err != nil {
+		return builder.String(), err
+	}
+
+	// 从最近的轮次往前挑，凑够预算就停止，再按时间顺序拼回去
+	var selected []MemoryTurn
+	for i := len(entries) - 1; i >= 0; i-- {
+		turn := entries[i].turn
+		if usedTokens+turn.Tokens > budget {
+			break
+		}
+		usedTokens += turn.Tokens
+		selected = append([]MemoryTurn{turn}, selected...)
+	}
+
+	if len(selected) > 0 {
+		builder.WriteString("最近对话：\n")
+		for _, turn := range selected {
+			builder.WriteString(fmt.Sprintf("%s: %s\n", turn.Role, turn.Content))
+		}
+	}
+
+	return builder.String(), nil
+}
+
+// readEntries 按时间顺序（旧到新）读取窗口内的全部记忆条目
+func (s *MemoryService) readEntries(ctx context.Context, userID int64) ([]memoryEntry, error) {
+	messages, err := s.redisClient.XRange(ctx, memoryStreamKey(userID), "-", "+").Result()
+	if err != nil {
+		return nil, fmt.Errorf("读取对话记忆失败: %w", err)
+	}
+
+	entries := make([]memoryEntry, 0, len(messages))
+	for _, m := range messages {
+		turn := MemoryTurn{
+			Role:    fmt.Sprint(m.Values["role"]),
+			Content: fmt.Sprint(m.Values["content"]),
+		}
+		if ts, err := strconv.ParseInt(fmt.Sprint(m.Values["ts"]), 10, 64); err == nil {
+			turn.Ts = ts
+		}
+		if tokens, err := strconv.Atoi(fmt.Sprint(m.Values["tokens"])); err == nil {
+			turn.Tokens = tokens
+		}
+		entries = append(entries, memoryEntry{id: m.ID, turn: turn})
+	}
+	return entries, nil
+}
+
+// summarizeIfOverflowing 当窗口内总 token 数超过 memoryWindowTokenBudget 时，把最老的
+// memorySummarizeBatch 轮连同已有摘要一起交给 LLM 压缩成新的滚动摘要，并从 Stream
+// 中删除这些已被摘要吸收的轮次。
+func (s *MemoryService) summarizeIfOverflowing(userID int64) {
+	ctx := context.Background()
+
+	entries, err := s.readEntries(ctx, userID)
+	if err != nil {
+		s.logger.Error("摘要对话记忆时读取失败", zap.Int64("userId", userID), zap.Error(err))
+		return
+	}
+
+	total := 0
+	for _, e := range entries {
+		total += e.turn.Tokens
+	}
+	if total <= memoryWindowTokenBudget {
+		return
+	}
+
+	batch := entries
+	if len(batch) > memorySummarizeBatch {
+		batch = batch[:memorySummarizeBatch]
+	}
+
+	existingSummary, err := s.redisClient.Get(ctx, memorySummaryKey(userID)).Result()
+	if err != nil && err != redis.Nil {
+		s.logger.Error("读取历史摘要失败", zap.Int64("userId", userID), zap.Error(err))
+		return
+	}
+
+	var builder strings.Builder
+	if existingSummary != "" {
+		builder.WriteString("已有摘要：" + existingSummary + "\n\n")
+	}
+	builder.WriteString("新增对话：\n")
+	for _, e := range batch {
+		builder.WriteString(fmt.Sprintf("%s: %s\n", e.turn.Role, e.turn.Content))
+	}
+	builder.WriteString("\n请把已有摘要和新增对话合并，压缩成一段简洁的滚动摘要，不超过200字。")
+
+	summary, err := client.SimpleChat(s.llmClient, memorySummarizeSystemPrompt, builder.String())
+	if err != nil {
+		s.logger.Error("生成滚动摘要失败", zap.Int64("userId", userID), zap.Error(err))
+		return
+	}
+
+	if err := s.redisClient.Set(ctx, memorySummaryKey(userID), summary, memoryTTL).Err(); err != nil {
+		s.logger.Error("写入滚动摘要失败", zap.Int64("userId", userID), zap.Error(err))
+		return
+	}
+
+	ids := make([]string, len(batch))
+	for i, e := range batch {
+		ids[i] = e.id
+	}
+	if err := s.redisClient.XDel(ctx, memoryStreamKey(userID), ids...).Err(); err != nil {
+		s.logger.Error("清理已摘要的对话轮次失败", zap.Int64("userId", userID), zap.Error(err))
+		return
+	}
+
+	s.logger.Info("滚动摘要已更新",
+		zap.Int64("userId", userID),
+		zap.Int("summarizedTurns", len(batch)))
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/supportbot/supportbot-go/internal/client"
+	"github.com/supportbot/supportbot-go/internal/vectorstore"
+	"go.uber.org/zap"
+)
+
+// rerankCacheTTL 重排分数在内存缓存中的存活时间，同一 (query, doc) 组合在此
+// 期间重复出现时直接复用分数，省去一次交叉编码器调用
+const rerankCacheTTL = 10 * time.Minute
+
+// Reranker 对初筛候选做二次精排的抽象，SearchKnowledge 在配置了 reranker 时
+// 会先用向量检索召回较大的候选集，再交给 Reranker 精排截取到 topK
+type Reranker interface {
+	// Rerank 根据 query 对 candidates 重新打分排序，返回前 topK 条
+	Rerank(query string, candidates []vectorstore.SearchResult, topK int) ([]vectorstore.SearchResult, error)
+}
+
+// NullReranker 直接按原有顺序截取前 topK，不做任何重排，用于关闭重排功能或测试
+type NullReranker struct{}
+
+// Rerank 实现 Reranker，原样截取前 topK 条
+func (NullReranker) Rerank(query string, candidates []vectorstore.SearchResult, topK int) ([]vectorstore.SearchResult, error) {
+	if len(candidates) > topK {
+		return candidates[:topK], nil
+	}
+	return candidates, nil
+}
+
+// dashScopeReranker 用 DashScope 的 gte-rerank 交叉编码器模型对候选文档重新打分
+type dashScopeReranker struct {
+	rerankClient *client.RerankClient
+}
+
+// NewDashScopeReranker 创建基于 DashScope gte-rerank 的 Reranker
+func NewDashScopeReranker(rerankClient *client.RerankClient) Reranker {
+	return &dashScopeReranker{rerankClient: rerankClient}
+}
+
+// Rerank 把候选文档内容交给交叉编码器打分，按分数降序截取前 topK
+func (r *dashScopeReranker) Rerank(query string, candidates []vectorstore.SearchResult, topK int) ([]vectorstore.SearchResult, error) {
+	if len(candidates) == 0 {
+		return candidates, nil
+	}
+
+	documents := make([]string, len(candidates))
+	for i, c := range candidates {
+		documents[i] = c.Document.Content
+	}
+
+	scored, err := r.rerankClient.Rerank(query, documents)
+	if err != nil {
+		return nil, err
+	}
+
+	reranked := make([]vectorstore.SearchResult, 0, len(scored))
+	for _, s := range scored {
+		if s.Index < 0 || s.Index >= len(candidates) {
+			continue
+		}
+		result := candidates[s.Index]
+		result.Score = s.Score
+		reranked = append(reranked, result)
+	}
+
+	sort.Slice(reranked, func(i, j int) bool { return reranked[i].Score > reranked[j].Score })
+	if len(reranked) > topK {
+		reranked = reranked[:topK]
+	}
+	return reranked, nil
+}
+
+// rerankCacheEntry 一条缓存的重排分数及其过期时间
+type rerankCacheEntry struct {
+	score     float64
+	expiresAt time.Time
+}
+
+// cachingReranker 包装另一个 Reranker，按 (query_hash, doc_id) 缓存重排分数，
+// 避免同一查询重复命中相同文档时再次调用代价较高的交叉编码器。
+type cachingReranker struct {
+	inner  Reranker
+	logger *zap.Logger
+
+	mu    sync.Mutex
+	cache map[string]rerankCacheEntry
+}
+
+// NewCachingReranker 包装 inner，为其加上带 TTL 的重排分数缓存
+func NewCachingReranker(inner Reranker, logger *zap.Logger) Reranker {
+	return &cachingReranker{
+		inner:  inner,
+		logger: logger,
+		cache:  make(map[string]rerankCacheEntry),
+	}
+}
+
+// Rerank 先从缓存中取已知分数，只把未命中缓存的候选交给 inner 重排，
+// 合并后按分数降序截取前 topK，并记录本次重排的耗时和命中情况
+func (r *cachingReranker) Rerank(query string, candidates []vectorstore.SearchResult, topK int) ([]vectorstore.SearchResult, error) {
+	start := time.Now()
+	queryHash := rerankQueryHash(query)
+
+	cached := make(map[string]float64)
+	uncached := make([]vectorstore.SearchResult, 0, len(candidates))
+
+	now := time.Now()
+	r.mu.Lock()
+	for _, c := range candidates {
+		if entry, ok := r.cache[queryHash+":"+c.Document.ID]; ok && entry.expiresAt.After(now) {
+			cached[c.Document.ID] = entry.score
+		} else {
+			uncached = append(uncached, c)
+		}
+	}
+	r.mu.Unlock()
+
+	merged := make([]vectorstore.SearchResult, 0, len(candidates))
+	for _, c := range candidates {
+		if score, ok := cached[c.Document.ID]; ok {
+			c.Score = score
+			merged = append(merged, c)
+		}
+	}
+
+	if len(uncached) > 0 {
+		freshlyRanked, err := r.inner.Rerank(query, uncached, len(uncached))
+		if err != nil {
+			return nil, err
+		}
+
+		expiresAt := time.Now().Add(rerankCacheTTL)
+		r.mu.Lock()
+		for _, c := range freshlyRanked {
+			r.cache[queryHash+":"+c.Document.ID] = rerankCacheEntry{score: c.Score, expiresAt: expiresAt}
+		}
+		r.mu.Unlock()
+
+		merged = append(merged, freshlyRanked...)
+	}
+
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Score > merged[j].Score })
+	if len(merged) > topK {
+		merged = merged[:topK]
+	}
+
+	r.logger.Info("重排完成",
+		zap.Duration("latency", time.Since(start)),
+		zap.Int("candidates", len(candidates)),
+		zap.Int("cacheHits", len(cached)),
+		zap.Int("cacheMisses", len(uncached)),
+		zap.Int("topK", len(merged)))
+
+	return merged, nil
+}
+
+// rerankQueryHash 把 query 哈希成定长字符串，作为缓存 key 的一部分
+func rerankQueryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
@@ -14,12 +16,32 @@ var (
 	ErrUserOffline = fmt.Errorf("用户不在线")
 )
 
+// clusterTTL 跨节点会话归属记录的 TTL，由 heartbeatChecker 每 30s 续期一次，
+// 留出两个心跳周期的冗余避免网络抖动导致归属被误判为过期
+const clusterTTL = 90 * time.Second
+
+// envelopeClose / envelopeDeliver ClusterBroker 转发消息的信封类型
+const (
+	envelopeClose   = "close"   // 通知接收节点关闭本地到该用户的连接（优雅接管）
+	envelopeDeliver = "deliver" // 携带一条需要下发给该用户的业务消息
+)
+
+// clusterEnvelope 节点间通过 ClusterBroker 转发的消息信封
+type clusterEnvelope struct {
+	Type    string          `json:"type"`
+	UserID  int64           `json:"userId"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
 // SessionService 会话管理服务
 type SessionService struct {
 	userSessions  map[int64]*model.UserSession // userId -> session
 	sessionToUser map[string]int64             // sessionId -> userId
 	mu            sync.RWMutex                 // 读写锁保护
 	logger        *zap.Logger
+
+	nodeID string        // 本节点唯一标识，装配 broker 后用于登记/订阅归属
+	broker ClusterBroker // 可选，装配后 SendMessageToUser 在用户连接在其他节点时会跨节点转发
 }
 
 // NewSessionService 创建会话管理服务
@@ -36,8 +58,73 @@ func NewSessionService(logger *zap.Logger) *SessionService {
 	return s
 }
 
+// SetClusterBroker 为会话服务装配一个可选的 ClusterBroker，使多副本部署下
+// SendMessageToUser 能把消息转发到用户实际连接的节点。nodeID 应在同一集群内
+// 唯一（例如 Pod 名或随机 UUID）。装配后立即订阅本节点频道，开始接收其他
+// 节点转发来的消息；传入 nil broker 等效于不装配，退化为单机模式。
+func (s *SessionService) SetClusterBroker(broker ClusterBroker, nodeID string) error {
+	s.broker = broker
+	s.nodeID = nodeID
+
+	if broker == nil {
+		return nil
+	}
+
+	return broker.Subscribe(context.Background(), nodeID, s.handleClusterMessage)
+}
+
+// handleClusterMessage 处理从其他节点转发来的信封：close 关闭本地连接完成
+// 优雅接管，deliver 把消息投递进本地用户的下行队列
+func (s *SessionService) handleClusterMessage(payload []byte) {
+	var envelope clusterEnvelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		s.logger.Error("解析跨节点信封失败", zap.Error(err))
+		return
+	}
+
+	switch envelope.Type {
+	case envelopeClose:
+		s.closeLocalConnection(envelope.UserID)
+	case envelopeDeliver:
+		if err := s.deliverLocal(envelope.UserID, json.RawMessage(envelope.Payload)); err != nil {
+			s.logger.Warn("跨节点转发的消息投递失败",
+				zap.Int64("userId", envelope.UserID), zap.Error(err))
+		}
+	default:
+		s.logger.Warn("未知的跨节点信封类型", zap.String("type", envelope.Type))
+	}
+}
+
+// closeLocalConnection 关闭本地到 userID 的 WebSocket 连接（如果挂在本节点）。
+// HandleWebSocket 的读循环会在 Conn.Close 后从 ReadJSON 返回错误退出，由其
+// defer 触发 RemoveUserBySessionID 完成清理，这里不需要重复清理 map。
+func (s *SessionService) closeLocalConnection(userID int64) {
+	s.mu.RLock()
+	session, ok := s.userSessions[userID]
+	s.mu.RUnlock()
+	if !ok {
+		return
+	}
+	session.Conn.Close()
+}
+
 // RegisterUser 注册用户会话
 func (s *SessionService) RegisterUser(userID int64, username string, conn *websocket.Conn, sessionID string, clientIP string) {
+	if s.broker != nil {
+		previousNodeID, err := s.broker.Register(context.Background(), userID, s.nodeID, clusterTTL)
+		if err != nil {
+			s.logger.Error("登记跨节点会话归属失败，跨节点转发可能不可用",
+				zap.Int64("userId", userID), zap.Error(err))
+		} else if previousNodeID != "" && previousNodeID != s.nodeID {
+			s.logger.Info("用户连接从其他节点迁移过来，通知旧节点关闭连接",
+				zap.Int64("userId", userID), zap.String("previousNode", previousNodeID))
+			closeMsg, _ := json.Marshal(clusterEnvelope{Type: envelopeClose, UserID: userID})
+			if err := s.broker.Publish(context.Background(), previousNodeID, closeMsg); err != nil {
+				s.logger.Error("通知旧节点关闭连接失败", zap.Int64("userId", userID), zap.Error(err))
+			}
+		}
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -47,22 +134,16 @@ func (s *SessionService) RegisterUser(userID int64, username string, conn *webso
 			zap.Int64("userId", userID),
 			zap.String("oldSessionId", existingSession.SessionID))
 		existingSession.Conn.Close()
+		existingSession.CloseOutbox()
 		delete(s.sessionToUser, existingSession.SessionID)
 	}
 
 	// 创建新会话
-	session := &model.UserSession{
-		UserID:        userID,
-		Username:      username,
-		Conn:          conn,
-		SessionID:     sessionID,
-		ClientIP:      clientIP,
-		LastHeartbeat: time.Now(),
-		MissedBeats:   0,
-	}
+	session := model.NewUserSession(userID, username, conn, sessionID, clientIP)
 
 	s.userSessions[userID] = session
 	s.sessionToUser[sessionID] = userID
+	go s.writePump(session)
 
 	s.logger.Info("用户会话注册成功",
 		zap.Int64("userId", userID),
@@ -70,29 +151,94 @@ func (s *SessionService) RegisterUser(userID int64, username string, conn *webso
 		zap.String("sessionId", sessionID))
 }
 
-// SendMessageToUser 向指定用户发送消息
-func (s *SessionService) SendMessageToUser(userID int64, message interface{}) error {
+// writePump 串行消费 session 的下行队列，把消息真正写入 WebSocket 连接；
+// 写入失败或队列被关闭都会结束该协程，失败时顺带清理会话。
+func (s *SessionService) writePump(session *model.UserSession) {
+	for message := range session.Outbox {
+		if err := session.WriteMessage(message); err != nil {
+			s.logger.Error("消息发送失败",
+				zap.Int64("userId", session.UserID),
+				zap.Error(err))
+			go s.RemoveUserByID(session.UserID)
+			return
+		}
+	}
+}
+
+// deliverLocal 把消息投递进本地 userID 的下行队列。当客户端消费跟不上、队列已满
+// 时（典型场景是流式增量帧堆积），丢弃队列中最旧的一帧腾出空间，避免调用方被
+// 慢客户端阻塞。仅在本节点确实持有该用户连接时可用，否则返回 ErrUserOffline。
+func (s *SessionService) deliverLocal(userID int64, message interface{}) error {
 	s.mu.RLock()
 	session, ok := s.userSessions[userID]
 	s.mu.RUnlock()
 
 	if !ok {
+		return ErrUserOffline
+	}
+
+	select {
+	case session.Outbox <- message:
+		return nil
+	default:
+	}
+
+	// 队列已满：丢弃最旧的一帧，为新消息腾出空间
+	select {
+	case <-session.Outbox:
+		s.logger.Warn("下行队列已满，丢弃最旧的一帧", zap.Int64("userId", userID))
+	default:
+	}
+
+	select {
+	case session.Outbox <- message:
+		return nil
+	default:
+		s.logger.Error("下行队列持续写满，消息被丢弃", zap.Int64("userId", userID))
+		return fmt.Errorf("用户下行队列已满")
+	}
+}
+
+// SendMessageToUser 向指定用户发送消息。本节点持有该用户连接时直接入队本地下行
+// 队列；装配了 ClusterBroker 且用户连接在其他节点时，序列化后通过 broker 转发
+// 过去，由该节点的 Subscribe 协程收到后投递进它本地的下行队列。
+func (s *SessionService) SendMessageToUser(userID int64, message interface{}) error {
+	s.mu.RLock()
+	_, local := s.userSessions[userID]
+	s.mu.RUnlock()
+
+	if local {
+		return s.deliverLocal(userID, message)
+	}
+
+	if s.broker == nil {
 		s.logger.Warn("用户不在线，消息发送失败", zap.Int64("userId", userID))
 		return ErrUserOffline
 	}
 
-	// WebSocket 写入需要加锁（通过 session 自己的方法）
-	err := session.WriteMessage(message)
+	ctx := context.Background()
+	nodeID, online, err := s.broker.Lookup(ctx, userID)
 	if err != nil {
-		s.logger.Error("消息发送失败",
-			zap.Int64("userId", userID),
-			zap.Error(err))
-		// 异步清理无效连接
-		go s.RemoveUserByID(userID)
-		return err
+		s.logger.Error("查询跨节点会话归属失败", zap.Int64("userId", userID), zap.Error(err))
+		return ErrUserOffline
+	}
+	if !online || nodeID == s.nodeID {
+		s.logger.Warn("用户不在线，消息发送失败", zap.Int64("userId", userID))
+		return ErrUserOffline
+	}
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("序列化消息失败: %w", err)
+	}
+	envelope, err := json.Marshal(clusterEnvelope{Type: envelopeDeliver, UserID: userID, Payload: payload})
+	if err != nil {
+		return fmt.Errorf("序列化跨节点信封失败: %w", err)
 	}
 
-	s.logger.Info("消息发送成功", zap.Int64("userId", userID))
+	if err := s.broker.Publish(ctx, nodeID, envelope); err != nil {
+		return fmt.Errorf("跨节点转发消息失败: %w", err)
+	}
 	return nil
 }
 
@@ -114,11 +260,18 @@ func (s *SessionService) UpdateHeartbeat(userID int64) bool {
 // RemoveUserBySessionID 根据 sessionId 移除会话
 func (s *SessionService) RemoveUserBySessionID(sessionID string) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if userID, ok := s.sessionToUser[sessionID]; ok {
+	userID, ok := s.sessionToUser[sessionID]
+	if ok {
+		if session, ok := s.userSessions[userID]; ok {
+			session.CloseOutbox()
+		}
 		delete(s.userSessions, userID)
 		delete(s.sessionToUser, sessionID)
+	}
+	s.mu.Unlock()
+
+	if ok {
+		s.unregisterCluster(userID)
 		s.logger.Info("用户会话已移除",
 			zap.Int64("userId", userID),
 			zap.String("sessionId", sessionID))
@@ -128,23 +281,49 @@ func (s *SessionService) RemoveUserBySessionID(sessionID string) {
 // RemoveUserByID 根据 userId 移除会话
 func (s *SessionService) RemoveUserByID(userID int64) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if session, ok := s.userSessions[userID]; ok {
+	session, ok := s.userSessions[userID]
+	if ok {
+		session.CloseOutbox()
 		delete(s.sessionToUser, session.SessionID)
 		delete(s.userSessions, userID)
+	}
+	s.mu.Unlock()
+
+	if ok {
+		s.unregisterCluster(userID)
 		s.logger.Info("用户会话已移除", zap.Int64("userId", userID))
 	}
 }
 
-// GetOnlineCount 获取在线用户数
+// unregisterCluster 会话在本地清理后，同步清理跨节点归属记录
+func (s *SessionService) unregisterCluster(userID int64) {
+	if s.broker == nil {
+		return
+	}
+	if err := s.broker.Unregister(context.Background(), userID, s.nodeID); err != nil {
+		s.logger.Error("清理跨节点会话归属失败", zap.Int64("userId", userID), zap.Error(err))
+	}
+}
+
+// GetOnlineCount 获取在线用户数。装配了 ClusterBroker 时返回集群整体在线数
+// （Redis Set 的 SCARD），否则返回本节点持有的连接数。
 func (s *SessionService) GetOnlineCount() int {
+	if s.broker != nil {
+		count, err := s.broker.OnlineCount(context.Background())
+		if err != nil {
+			s.logger.Error("查询集群在线数失败", zap.Error(err))
+		} else {
+			return int(count)
+		}
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	return len(s.userSessions)
 }
 
-// heartbeatChecker 心跳检测器
+// heartbeatChecker 心跳检测器：检查本地连接的客户端心跳是否超时，装配了
+// ClusterBroker 时顺带续期每个本地会话的跨节点归属 TTL
 func (s *SessionService) heartbeatChecker() {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
@@ -165,12 +344,21 @@ func (s *SessionService) heartbeatChecker() {
 						zap.Int("missedBeats", session.MissedBeats))
 
 					session.Conn.Close()
+					session.CloseOutbox()
 					delete(s.userSessions, userID)
 					delete(s.sessionToUser, session.SessionID)
-				} else {
-					s.logger.Warn("用户心跳丢失",
-						zap.Int64("userId", userID),
-						zap.Int("missedBeats", session.MissedBeats))
+					s.unregisterCluster(userID)
+					continue
+				}
+
+				s.logger.Warn("用户心跳丢失",
+					zap.Int64("userId", userID),
+					zap.Int("missedBeats", session.MissedBeats))
+			}
+
+			if s.broker != nil {
+				if err := s.broker.Refresh(context.Background(), userID, s.nodeID, clusterTTL); err != nil {
+					s.logger.Error("续期跨节点会话归属失败", zap.Int64("userId", userID), zap.Error(err))
 				}
 			}
 		}
@@ -178,4 +366,3 @@ func (s *SessionService) heartbeatChecker() {
 		s.mu.Unlock()
 	}
 }
-
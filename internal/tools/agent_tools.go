@@ -0,0 +1,117 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"go.uber.org/zap"
+)
+
+// RegisterAgentTools 注册供工具调用 Agent 使用的业务工具：订单状态、商品库存查询，
+// 以及桥接到 knowledge-rag 服务的知识库检索，使 order.status / product.inquiry 这类
+// 分类不再只是路由转发，而是真正能给出答案。
+func RegisterAgentTools(registry *Registry, ragServiceURL string, logger *zap.Logger) error {
+	httpClient := &http.Client{}
+
+	orderStatusTool := &Tool{
+		Name:        "query_order_status",
+		Description: "查询订单当前状态和预计送达时间",
+		Parameters: ParameterSchema{
+			Type: "object",
+			Properties: map[string]Property{
+				"order_id": {Type: "string", Description: "订单号，例如：20240101001"},
+			},
+			Required: []string{"order_id"},
+		},
+		Handler: func(_ context.Context, params map[string]interface{}) (interface{}, error) {
+			orderID, ok := params["order_id"].(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid order_id")
+			}
+
+			// 模拟订单服务查询
+			orders := map[string]map[string]interface{}{
+				"20240101001": {"order_id": "20240101001", "status": "配送中", "estimated_delivery": "明天送达"},
+				"20240101002": {"order_id": "20240101002", "status": "待发货", "estimated_delivery": "暂无"},
+			}
+			if order, ok := orders[orderID]; ok {
+				return order, nil
+			}
+			return map[string]interface{}{"error": "订单不存在"}, nil
+		},
+	}
+
+	productStockTool := &Tool{
+		Name:        "query_product_stock",
+		Description: "查询商品当前库存数量",
+		Parameters: ParameterSchema{
+			Type: "object",
+			Properties: map[string]Property{
+				"product_id": {Type: "string", Description: "商品ID，例如：30001"},
+			},
+			Required: []string{"product_id"},
+		},
+		Handler: func(_ context.Context, params map[string]interface{}) (interface{}, error) {
+			productID, ok := params["product_id"].(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid product_id")
+			}
+
+			// 模拟商品服务查询
+			stock := map[string]int{"30001": 128, "30002": 45, "30003": 320}
+			if s, ok := stock[productID]; ok {
+				return map[string]interface{}{"product_id": productID, "stock": s, "available": s > 0}, nil
+			}
+			return map[string]interface{}{"error": "商品不存在"}, nil
+		},
+	}
+
+	searchKnowledgeTool := &Tool{
+		Name:        "search_knowledge",
+		Description: "检索知识库，适用于退换货政策、使用说明等非订单/商品类问题",
+		Parameters: ParameterSchema{
+			Type: "object",
+			Properties: map[string]Property{
+				"query": {Type: "string", Description: "要检索的问题"},
+			},
+			Required: []string{"query"},
+		},
+		Handler: func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+			query, ok := params["query"].(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid query")
+			}
+
+			reqURL := fmt.Sprintf("%s/api/knowledge/search?q=%s", ragServiceURL, url.QueryEscape(query))
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+			if err != nil {
+				return nil, fmt.Errorf("创建请求失败: %w", err)
+			}
+			resp, err := httpClient.Do(req)
+			if err != nil {
+				return nil, fmt.Errorf("调用知识库服务失败: %w", err)
+			}
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return nil, fmt.Errorf("读取知识库响应失败: %w", err)
+			}
+
+			logger.Info("search_knowledge 工具调用完成", zap.String("query", query))
+			return string(body), nil
+		},
+	}
+
+	for _, t := range []*Tool{orderStatusTool, productStockTool, searchKnowledgeTool} {
+		if err := registry.Register(t); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("Agent 工具注册完成", zap.Int("count", 3))
+	return nil
+}
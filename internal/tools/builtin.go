@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -25,7 +26,7 @@ func RegisterBuiltinTools(registry *Registry, logger *zap.Logger) error {
 			},
 			Required: []string{"product_id"},
 		},
-		Handler: func(params map[string]interface{}) (interface{}, error) {
+		Handler: func(_ context.Context, params map[string]interface{}) (interface{}, error) {
 			productID, ok := params["product_id"].(string)
 			if !ok {
 				return nil, fmt.Errorf("invalid product_id")
@@ -87,7 +88,7 @@ func RegisterBuiltinTools(registry *Registry, logger *zap.Logger) error {
 			},
 			Required: []string{"order_id"},
 		},
-		Handler: func(params map[string]interface{}) (interface{}, error) {
+		Handler: func(_ context.Context, params map[string]interface{}) (interface{}, error) {
 			orderID, ok := params["order_id"].(string)
 			if !ok {
 				return nil, fmt.Errorf("invalid order_id")
@@ -142,7 +143,7 @@ func RegisterBuiltinTools(registry *Registry, logger *zap.Logger) error {
 			},
 			Required: []string{"order_id"},
 		},
-		Handler: func(params map[string]interface{}) (interface{}, error) {
+		Handler: func(_ context.Context, params map[string]interface{}) (interface{}, error) {
 			orderID, ok := params["order_id"].(string)
 			if !ok {
 				return nil, fmt.Errorf("invalid order_id")
@@ -150,11 +151,11 @@ func RegisterBuiltinTools(registry *Registry, logger *zap.Logger) error {
 
 			// 模拟物流信息
 			tracking := map[string]interface{}{
-				"order_id":      orderID,
-				"tracking_no":   "SF1234567890",
-				"carrier":       "顺丰速运",
-				"current_location": "北京分拨中心",
-				"status":        "运输中",
+				"order_id":           orderID,
+				"tracking_no":        "SF1234567890",
+				"carrier":            "顺丰速运",
+				"current_location":   "北京分拨中心",
+				"status":             "运输中",
 				"estimated_delivery": time.Now().Add(24 * time.Hour).Format("2006-01-02"),
 				"tracking_info": []map[string]string{
 					{
@@ -197,7 +198,7 @@ func RegisterBuiltinTools(registry *Registry, logger *zap.Logger) error {
 			},
 			Required: []string{"product_id"},
 		},
-		Handler: func(params map[string]interface{}) (interface{}, error) {
+		Handler: func(_ context.Context, params map[string]interface{}) (interface{}, error) {
 			productID, ok := params["product_id"].(string)
 			if !ok {
 				return nil, fmt.Errorf("invalid product_id")
@@ -237,4 +238,3 @@ func RegisterBuiltinTools(registry *Registry, logger *zap.Logger) error {
 	logger.Info("内置工具注册完成", zap.Int("count", len(tools)))
 	return nil
 }
-
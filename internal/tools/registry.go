@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
@@ -74,8 +75,23 @@ func (r *Registry) GetFunctionDefs() []map[string]interface{} {
 	return defs
 }
 
-// Execute 执行工具调用
-func (r *Registry) Execute(toolCall ToolCall) (interface{}, error) {
+// AsFunctionDefs 按 OpenAI/DashScope 函数调用 schema 封装全部工具
+// （{"type":"function","function":{...}}），可直接作为 tools 参数传给 LLM
+func (r *Registry) AsFunctionDefs() []map[string]interface{} {
+	toolList := r.List()
+	defs := make([]map[string]interface{}, len(toolList))
+	for i, tool := range toolList {
+		defs[i] = map[string]interface{}{
+			"type":     "function",
+			"function": tool.ToFunctionDef(),
+		}
+	}
+	return defs
+}
+
+// Execute 执行工具调用：获取工具、解析参数、按 Tool.Parameters 的 JSON Schema
+// 校验参数，再真正执行。ctx 透传给 Handler，用于约束外部调用的超时。
+func (r *Registry) Execute(ctx context.Context, toolCall ToolCall) (interface{}, error) {
 	r.logger.Info("执行工具调用",
 		zap.String("tool", toolCall.Function.Name),
 		zap.String("callId", toolCall.ID))
@@ -92,8 +108,16 @@ func (r *Registry) Execute(toolCall ToolCall) (interface{}, error) {
 		return nil, err
 	}
 
+	// 按 JSON Schema 校验参数
+	if err := tool.Parameters.Validate(params); err != nil {
+		r.logger.Error("工具参数校验失败",
+			zap.String("tool", toolCall.Function.Name),
+			zap.Error(err))
+		return nil, err
+	}
+
 	// 执行工具
-	result, err := tool.Execute(params)
+	result, err := tool.Execute(ctx, params)
 	if err != nil {
 		r.logger.Error("工具执行失败",
 			zap.String("tool", toolCall.Function.Name),
@@ -1,46 +1,49 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 )
 
 // Tool 工具定义（类似 OpenAI Function Calling）
 type Tool struct {
-	Name        string          `json:"name"`                  // 工具名称
-	Description string          `json:"description"`            // 工具描述
-	Parameters  ParameterSchema `json:"parameters"`             // 参数定义
-	Handler     ToolHandler     `json:"-"`                      // 工具处理函数（不序列化）
+	Name        string          `json:"name"`        // 工具名称
+	Description string          `json:"description"` // 工具描述
+	Parameters  ParameterSchema `json:"parameters"`  // 参数定义
+	Handler     ToolHandler     `json:"-"`           // 工具处理函数（不序列化）
 }
 
 // ParameterSchema JSON Schema 格式的参数定义
 type ParameterSchema struct {
-	Type       string              `json:"type"`        // "object"
-	Properties map[string]Property `json:"properties"`  // 参数属性
-	Required   []string            `json:"required"`    // 必需参数
+	Type       string              `json:"type"`       // "object"
+	Properties map[string]Property `json:"properties"` // 参数属性
+	Required   []string            `json:"required"`   // 必需参数
 }
 
 // Property 参数属性
 type Property struct {
-	Type        string   `json:"type"`                   // string, number, boolean, array, object
-	Description string   `json:"description"`             // 参数描述
-	Enum        []string `json:"enum,omitempty"`          // 枚举值
+	Type        string   `json:"type"`           // string, number, boolean, array, object
+	Description string   `json:"description"`    // 参数描述
+	Enum        []string `json:"enum,omitempty"` // 枚举值
 }
 
-// ToolHandler 工具处理函数
-type ToolHandler func(params map[string]interface{}) (interface{}, error)
+// ToolHandler 工具处理函数。ctx 携带调用方设置的超时，发起外部请求的 Handler
+// 应该用它构造可取消的请求（如 http.NewRequestWithContext）；不发起 IO 的 Handler
+// 可以忽略它。
+type ToolHandler func(ctx context.Context, params map[string]interface{}) (interface{}, error)
 
 // ToolCall LLM 返回的工具调用请求
 type ToolCall struct {
-	ID       string                 `json:"id"`        // 调用 ID
-	Type     string                 `json:"type"`      // "function"
-	Function ToolCallFunction       `json:"function"`
+	ID       string           `json:"id"`   // 调用 ID
+	Type     string           `json:"type"` // "function"
+	Function ToolCallFunction `json:"function"`
 }
 
 // ToolCallFunction 函数调用详情
 type ToolCallFunction struct {
-	Name      string                 `json:"name"`
-	Arguments string                 `json:"arguments"` // JSON 字符串
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON 字符串
 }
 
 // ToolResult 工具执行结果
@@ -50,6 +53,75 @@ type ToolResult struct {
 	Error      string      `json:"error,omitempty"`
 }
 
+// Validate 校验 params 是否满足 schema：必需参数是否齐全、已出现的参数类型和枚举值
+// 是否匹配。只实现 JSON Schema 里用得上的这几条规则，不是通用的 JSON Schema 校验器，
+// 但足以拦截模型产出的明显错误参数（类型不对、漏填必需字段、枚举值超出范围）。
+func (p ParameterSchema) Validate(params map[string]interface{}) error {
+	for _, name := range p.Required {
+		if _, ok := params[name]; !ok {
+			return fmt.Errorf("缺少必需参数: %s", name)
+		}
+	}
+
+	for name, value := range params {
+		prop, ok := p.Properties[name]
+		if !ok {
+			continue
+		}
+		if err := prop.validateType(name, value); err != nil {
+			return err
+		}
+		if err := prop.validateEnum(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateType 校验单个参数值是否匹配声明的 JSON Schema 类型
+func (p Property) validateType(name string, value interface{}) error {
+	switch p.Type {
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("参数 %s 类型应为 string", name)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("参数 %s 类型应为 number", name)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("参数 %s 类型应为 boolean", name)
+		}
+	case "array":
+		if _, ok := value.([]interface{}); !ok {
+			return fmt.Errorf("参数 %s 类型应为 array", name)
+		}
+	case "object":
+		if _, ok := value.(map[string]interface{}); !ok {
+			return fmt.Errorf("参数 %s 类型应为 object", name)
+		}
+	}
+	return nil
+}
+
+// validateEnum 校验字符串参数的取值是否在声明的枚举范围内
+func (p Property) validateEnum(name string, value interface{}) error {
+	if len(p.Enum) == 0 {
+		return nil
+	}
+	str, ok := value.(string)
+	if !ok {
+		return nil
+	}
+	for _, allowed := range p.Enum {
+		if str == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("参数 %s 的值 %q 不在允许的枚举范围内", name, str)
+}
+
 // ParseArguments 解析参数
 func (tc *ToolCall) ParseArguments() (map[string]interface{}, error) {
 	var params map[string]interface{}
@@ -60,11 +132,11 @@ func (tc *ToolCall) ParseArguments() (map[string]interface{}, error) {
 }
 
 // Execute 执行工具
-func (t *Tool) Execute(params map[string]interface{}) (interface{}, error) {
+func (t *Tool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	if t.Handler == nil {
 		return nil, fmt.Errorf("tool handler not implemented: %s", t.Name)
 	}
-	return t.Handler(params)
+	return t.Handler(ctx, params)
 }
 
 // ToFunctionDef 转换为 LLM Function 定义格式
@@ -75,4 +147,3 @@ func (t *Tool) ToFunctionDef() map[string]interface{} {
 		"parameters":  t.Parameters,
 	}
 }
-
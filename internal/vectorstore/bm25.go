@@ -0,0 +1,138 @@
+package vectorstore
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// BM25Index 基于 Okapi BM25 的倒排索引，用于补足向量检索在精确词/编号命中上的
+// 短板（SKU、错误码、商品名等电商场景常见的关键词查询）。
+type BM25Index struct {
+	k1, b     float64
+	tokenizer Tokenizer
+
+	mu        sync.RWMutex
+	postings  map[string]map[string]int // term -> docID -> 词频
+	docLength map[string]int            // docID -> token 数
+	totalLen  int
+}
+
+// NewBM25Index 创建 BM25 索引，k1=1.5，b=0.75 为常用默认值，使用默认的
+// Tokenize 分词器（中文 bigram + 英文空白分词词干化）
+func NewBM25Index() *BM25Index {
+	return NewBM25IndexWithTokenizer(Tokenize)
+}
+
+// NewBM25IndexWithTokenizer 创建 BM25 索引并替换默认分词器，便于接入更精确的
+// 分词实现（例如 jieba）而无需改动 BM25Index 本身
+func NewBM25IndexWithTokenizer(tokenizer Tokenizer) *BM25Index {
+	return &BM25Index{
+		k1:        1.5,
+		b:         0.75,
+		tokenizer: tokenizer,
+		postings:  make(map[string]map[string]int),
+		docLength: make(map[string]int),
+	}
+}
+
+// AddDocument 对文档内容分词并写入倒排索引；重复添加同一 ID 会先移除旧条目
+func (idx *BM25Index) AddDocument(id, content string) {
+	tokens := idx.tokenizer(content)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.removeLocked(id)
+
+	freq := make(map[string]int)
+	for _, t := range tokens {
+		freq[t]++
+	}
+	for term, f := range freq {
+		if idx.postings[term] == nil {
+			idx.postings[term] = make(map[string]int)
+		}
+		idx.postings[term][id] = f
+	}
+
+	idx.docLength[id] = len(tokens)
+	idx.totalLen += len(tokens)
+}
+
+// Remove 将文档从索引中移除
+func (idx *BM25Index) Remove(id string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.removeLocked(id)
+}
+
+func (idx *BM25Index) removeLocked(id string) {
+	if oldLen, exists := idx.docLength[id]; exists {
+		idx.totalLen -= oldLen
+		delete(idx.docLength, id)
+	}
+	for term, docs := range idx.postings {
+		if _, ok := docs[id]; ok {
+			delete(docs, id)
+			if len(docs) == 0 {
+				delete(idx.postings, term)
+			}
+		}
+	}
+}
+
+// BM25Result 单条 BM25 检索结果
+type BM25Result struct {
+	DocID string
+	Score float64
+}
+
+// Search 返回与 query 最相关的 Top-K 文档，按 BM25 分值降序排列
+func (idx *BM25Index) Search(query string, topK int) []BM25Result {
+	terms := idx.tokenizer(query)
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	numDocs := len(idx.docLength)
+	if numDocs == 0 || len(terms) == 0 {
+		return nil
+	}
+	avgDocLen := float64(idx.totalLen) / float64(numDocs)
+
+	scores := make(map[string]float64)
+	for _, term := range terms {
+		postings := idx.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+
+		// idf = ln((N - n + 0.5) / (n + 0.5) + 1)，加 1 保证非负
+		n := float64(len(postings))
+		idf := math.Log((float64(numDocs)-n+0.5)/(n+0.5) + 1)
+
+		for docID, f := range postings {
+			docLen := float64(idx.docLength[docID])
+			denom := float64(f) + idx.k1*(1-idx.b+idx.b*docLen/avgDocLen)
+			scores[docID] += idf * (float64(f) * (idx.k1 + 1)) / denom
+		}
+	}
+
+	results := make([]BM25Result, 0, len(scores))
+	for docID, score := range scores {
+		results = append(results, BM25Result{DocID: docID, Score: score})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > topK {
+		results = results[:topK]
+	}
+	return results
+}
+
+// Count 返回索引中的文档数
+func (idx *BM25Index) Count() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docLength)
+}
@@ -0,0 +1,196 @@
+package vectorstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+var documentsBucket = []byte("documents")
+
+// BoltVectorStore 基于 BoltDB 的持久化向量存储，知识库数据写入本地文件，
+// 服务重启后不会丢失。检索仍然是对全部文档做暴力余弦扫描，数据量较大时
+// 请改用 HNSWVectorStore。
+type BoltVectorStore struct {
+	db     *bbolt.DB
+	logger *zap.Logger
+}
+
+// NewBoltVectorStore 打开（或创建）BoltDB 数据文件作为向量存储
+func NewBoltVectorStore(path string, logger *zap.Logger) (*BoltVectorStore, error) {
+	db, err := bbolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("打开 BoltDB 失败: %w", err)
+	}
+
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(documentsBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化 bucket 失败: %w", err)
+	}
+
+	logger.Info("BoltDB 向量存储已就绪", zap.String("path", path))
+	return &BoltVectorStore{db: db, logger: logger}, nil
+}
+
+// AddDocument 添加文档
+func (s *BoltVectorStore) AddDocument(doc Document) error {
+	if doc.ID == "" {
+		return fmt.Errorf("document ID cannot be empty")
+	}
+	if len(doc.Vector) == 0 {
+		return fmt.Errorf("document vector cannot be empty")
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("序列化文档失败: %w", err)
+	}
+
+	if err := s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(documentsBucket).Put([]byte(doc.ID), data)
+	}); err != nil {
+		return fmt.Errorf("写入文档失败: %w", err)
+	}
+
+	s.logger.Info("文档已持久化", zap.String("id", doc.ID), zap.Int("dimension", len(doc.Vector)))
+	return nil
+}
+
+// AddDocuments 批量添加文档
+func (s *BoltVectorStore) AddDocuments(docs []Document) error {
+	for _, doc := range docs {
+		if err := s.AddDocument(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Search 遍历 BoltDB 中的全部文档计算余弦相似度，返回 Top-K
+func (s *BoltVectorStore) Search(queryVector []float64, topK int, minScore float64) ([]SearchResult, error) {
+	return s.SearchWithFilter(queryVector, topK, minScore, nil)
+}
+
+// SearchWithFilter 与 Search 相同，但先按 filter 过滤元数据再计算相似度
+func (s *BoltVectorStore) SearchWithFilter(queryVector []float64, topK int, minScore float64, filter MetadataFilter) ([]SearchResult, error) {
+	if len(queryVector) == 0 {
+		return nil, fmt.Errorf("query vector cannot be empty")
+	}
+
+	var results []SearchResult
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(documentsBucket).ForEach(func(_, v []byte) error {
+			var doc Document
+			if err := json.Unmarshal(v, &doc); err != nil {
+				return fmt.Errorf("解析文档失败: %w", err)
+			}
+			if !filter.Matches(doc.Metadata) {
+				return nil
+			}
+			if score := CosineSimilarity(queryVector, doc.Vector); score >= minScore {
+				results = append(results, SearchResult{Document: doc, Score: score, Distance: 1 - score})
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > topK {
+		results = results[:topK]
+	}
+	return results, nil
+}
+
+// GetDocument 按 ID 获取文档
+func (s *BoltVectorStore) GetDocument(id string) (*Document, error) {
+	var doc Document
+	found := false
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(documentsBucket).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &doc)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取文档失败: %w", err)
+	}
+	if !found {
+		return nil, fmt.Errorf("document not found: %s", id)
+	}
+	return &doc, nil
+}
+
+// ListIDsByMetadata 遍历 BoltDB 中的全部文档，返回所有满足 filter 的文档 ID
+func (s *BoltVectorStore) ListIDsByMetadata(filter MetadataFilter) ([]string, error) {
+	var ids []string
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(documentsBucket).ForEach(func(k, v []byte) error {
+			var doc Document
+			if err := json.Unmarshal(v, &doc); err != nil {
+				return fmt.Errorf("解析文档失败: %w", err)
+			}
+			if filter.Matches(doc.Metadata) {
+				ids = append(ids, string(k))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// DeleteDocument 按 ID 删除文档
+func (s *BoltVectorStore) DeleteDocument(id string) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(documentsBucket)
+		if b.Get([]byte(id)) == nil {
+			return fmt.Errorf("document not found: %s", id)
+		}
+		return b.Delete([]byte(id))
+	})
+	if err != nil {
+		return err
+	}
+	s.logger.Info("文档已删除", zap.String("id", id))
+	return nil
+}
+
+// Count 获取文档总数
+func (s *BoltVectorStore) Count() int {
+	count := 0
+	s.db.View(func(tx *bbolt.Tx) error {
+		count = tx.Bucket(documentsBucket).Stats().KeyN
+		return nil
+	})
+	return count
+}
+
+// Clear 清空所有文档
+func (s *BoltVectorStore) Clear() error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		if err := tx.DeleteBucket(documentsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(documentsBucket)
+		return err
+	})
+}
+
+// Close 关闭底层数据库文件
+func (s *BoltVectorStore) Close() error {
+	return s.db.Close()
+}
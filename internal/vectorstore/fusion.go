@@ -0,0 +1,32 @@
+package vectorstore
+
+// rrfK 是 RRF 公式中的平滑常数，用于降低头部排名差异的权重影响，60 是文献中的常用取值
+const rrfK = 60
+
+// ReciprocalRankFusion 按照 score(d) = Σ 1/(k+rank_i(d)) 融合多路排序结果（排名从 1 开始计），
+// 用于合并 BM25 等词法检索与向量检索这类分值量纲不同的排序结果。
+func ReciprocalRankFusion(rankings ...[]string) map[string]float64 {
+	weights := make([]float64, len(rankings))
+	for i := range weights {
+		weights[i] = 1.0
+	}
+	return WeightedReciprocalRankFusion(rankings, weights)
+}
+
+// WeightedReciprocalRankFusion 与 ReciprocalRankFusion 相同，但允许为每一路排序结果
+// 分配不同权重：score(d) = Σ weight_i * 1/(k+rank_i(d))，用于让调用方偏向向量检索
+// 或 BM25 检索中的某一路。rankings 与 weights 必须等长。
+func WeightedReciprocalRankFusion(rankings [][]string, weights []float64) map[string]float64 {
+	scores := make(map[string]float64)
+	for i, ranking := range rankings {
+		weight := 1.0
+		if i < len(weights) {
+			weight = weights[i]
+		}
+		for j, docID := range ranking {
+			rank := j + 1
+			scores[docID] += weight / float64(rrfK+rank)
+		}
+	}
+	return scores
+}
@@ -0,0 +1,518 @@
+package vectorstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// hnswBruteForceThreshold 文档数低于该阈值时直接退化为暴力扫描，
+// 避免图结构在小数据集上的构建/查询开销反而超过收益。
+const hnswBruteForceThreshold = 1000
+
+// HNSWConfig HNSW 索引参数
+type HNSWConfig struct {
+	M              int // 每个节点每层保留的邻居数，默认 16
+	EfConstruction int // 建图时的候选集大小，默认 200
+	EfSearch       int // 查询时的候选集大小，默认取 max(efSearch, topK)
+}
+
+// DefaultHNSWConfig 返回推荐的默认参数
+func DefaultHNSWConfig() HNSWConfig {
+	return HNSWConfig{M: 16, EfConstruction: 200, EfSearch: 64}
+}
+
+type hnswNode struct {
+	ID        string
+	Level     int
+	Neighbors [][]string // 每层的邻居 ID 列表，Neighbors[l] 对应第 l 层
+}
+
+// HNSWVectorStore 基于 HNSW（Hierarchical Navigable Small World）的近似最近邻向量存储。
+// 在文档数超过 hnswBruteForceThreshold 后检索复杂度从 O(N) 降为近似 O(log N)，
+// 文档数较少时自动退化为暴力扫描以保证召回率。
+type HNSWVectorStore struct {
+	cfg HNSWConfig
+
+	mu        sync.RWMutex
+	documents map[string]*Document
+	nodes     map[string]*hnswNode
+	entryID   string // 当前图的入口点（最高层节点）
+	maxLevel  int
+
+	snapshotPath string
+	logger       *zap.Logger
+}
+
+// NewHNSWVectorStore 创建 HNSW 向量存储。snapshotPath 为空时不持久化。
+func NewHNSWVectorStore(cfg HNSWConfig, snapshotPath string, logger *zap.Logger) *HNSWVectorStore {
+	if cfg.M <= 0 {
+		cfg.M = DefaultHNSWConfig().M
+	}
+	if cfg.EfConstruction <= 0 {
+		cfg.EfConstruction = DefaultHNSWConfig().EfConstruction
+	}
+	if cfg.EfSearch <= 0 {
+		cfg.EfSearch = DefaultHNSWConfig().EfSearch
+	}
+
+	s := &HNSWVectorStore{
+		cfg:          cfg,
+		documents:    make(map[string]*Document),
+		nodes:        make(map[string]*hnswNode),
+		maxLevel:     -1,
+		snapshotPath: snapshotPath,
+		logger:       logger,
+	}
+
+	if snapshotPath != "" {
+		if err := s.LoadSnapshot(snapshotPath); err != nil && !os.IsNotExist(err) {
+			logger.Warn("加载 HNSW 快照失败，将从空索引启动", zap.Error(err))
+		}
+	}
+
+	return s
+}
+
+// AddDocument 插入文档并更新图结构
+func (s *HNSWVectorStore) AddDocument(doc Document) error {
+	if doc.ID == "" {
+		return fmt.Errorf("document ID cannot be empty")
+	}
+	if len(doc.Vector) == 0 {
+		return fmt.Errorf("document vector cannot be empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.documents[doc.ID] = &doc
+	s.insertNode(doc.ID)
+
+	if s.snapshotPath != "" {
+		if err := s.saveSnapshotLocked(); err != nil {
+			s.logger.Warn("保存 HNSW 快照失败", zap.Error(err))
+		}
+	}
+
+	s.logger.Info("文档已插入 HNSW 索引", zap.String("id", doc.ID), zap.Int("docCount", len(s.documents)))
+	return nil
+}
+
+// AddDocuments 批量插入文档
+func (s *HNSWVectorStore) AddDocuments(docs []Document) error {
+	for _, doc := range docs {
+		if err := s.AddDocument(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// randomLevel 按概率 p = 1/ln(M) 采样插入层级，层数越高概率越低
+func (s *HNSWVectorStore) randomLevel() int {
+	p := 1.0 / math.Log(float64(s.cfg.M))
+	level := 0
+	for rand.Float64() < p {
+		level++
+	}
+	return level
+}
+
+// insertNode 调用方必须持有写锁。在自顶向下的每一层用贪心搜索找到入口点，
+// 在 level <= 节点层级的各层做 efConstruction 宽度的束搜索，并为邻居建立双向边。
+func (s *HNSWVectorStore) insertNode(id string) {
+	level := s.randomLevel()
+	node := &hnswNode{ID: id, Level: level, Neighbors: make([][]string, level+1)}
+	s.nodes[id] = node
+
+	if s.entryID == "" {
+		s.entryID = id
+		s.maxLevel = level
+		return
+	}
+
+	vec := s.documents[id].Vector
+	entry := s.entryID
+
+	// 从最高层向下贪心搜索，找到每层更靠近目标的入口点
+	for l := s.maxLevel; l > level; l-- {
+		entry = s.greedySearchLayer(vec, entry, l)
+	}
+
+	for l := min(level, s.maxLevel); l >= 0; l-- {
+		candidates := s.searchLayer(vec, entry, s.cfg.EfConstruction, l)
+		neighbors := s.selectNeighborsHeuristic(vec, candidates, s.cfg.M)
+		node.Neighbors[l] = neighbors
+
+		for _, nid := range neighbors {
+			s.addBidirectionalEdge(nid, id, l)
+		}
+		if len(candidates) > 0 {
+			entry = candidates[0].id
+		}
+	}
+
+	if level > s.maxLevel {
+		s.maxLevel = level
+		s.entryID = id
+	}
+}
+
+// addBidirectionalEdge 为 a 添加指向 b 的边，并在超过 M 个邻居时按距离裁剪
+func (s *HNSWVectorStore) addBidirectionalEdge(a, b string, layer int) {
+	n, ok := s.nodes[a]
+	if !ok || layer > n.Level {
+		return
+	}
+	if len(n.Neighbors) <= layer {
+		grown := make([][]string, layer+1)
+		copy(grown, n.Neighbors)
+		n.Neighbors = grown
+	}
+	n.Neighbors[layer] = append(n.Neighbors[layer], b)
+
+	if len(n.Neighbors[layer]) > s.cfg.M {
+		vec := s.documents[a].Vector
+		candidates := make([]candidate, 0, len(n.Neighbors[layer]))
+		for _, nid := range n.Neighbors[layer] {
+			candidates = append(candidates, candidate{id: nid, score: CosineSimilarity(vec, s.documents[nid].Vector)})
+		}
+		n.Neighbors[layer] = s.selectNeighborsHeuristic(vec, candidates, s.cfg.M)
+	}
+}
+
+type candidate struct {
+	id    string
+	score float64 // 余弦相似度，越大越近
+}
+
+// greedySearchLayer 在单层上从 entry 出发贪心移动到最近邻，用于上层粗定位
+func (s *HNSWVectorStore) greedySearchLayer(query []float64, entry string, layer int) string {
+	current := entry
+	currentScore := CosineSimilarity(query, s.documents[current].Vector)
+
+	for {
+		improved := false
+		for _, nid := range s.layerNeighbors(current, layer) {
+			score := CosineSimilarity(query, s.documents[nid].Vector)
+			if score > currentScore {
+				current, currentScore = nid, score
+				improved = true
+			}
+		}
+		if !improved {
+			return current
+		}
+	}
+}
+
+// searchLayer 以 ef 为候选集宽度做束搜索，返回按相似度降序的候选列表
+func (s *HNSWVectorStore) searchLayer(query []float64, entry string, ef int, layer int) []candidate {
+	visited := map[string]bool{entry: true}
+	entryScore := CosineSimilarity(query, s.documents[entry].Vector)
+
+	candidates := []candidate{{entry, entryScore}}
+	results := []candidate{{entry, entryScore}}
+
+	for len(candidates) > 0 {
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+		best := candidates[0]
+		candidates = candidates[1:]
+
+		sort.Slice(results, func(i, j int) bool { return results[i].score > results[j].score })
+		worstResult := results[len(results)-1]
+		if best.score < worstResult.score && len(results) >= ef {
+			break
+		}
+
+		for _, nid := range s.layerNeighbors(best.id, layer) {
+			if visited[nid] {
+				continue
+			}
+			visited[nid] = true
+			score := CosineSimilarity(query, s.documents[nid].Vector)
+			candidates = append(candidates, candidate{nid, score})
+			results = append(results, candidate{nid, score})
+
+			sort.Slice(results, func(i, j int) bool { return results[i].score > results[j].score })
+			if len(results) > ef {
+				results = results[:ef]
+			}
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].score > results[j].score })
+	return results
+}
+
+// layerNeighbors 返回节点在指定层的已有邻居，节点层级低于 layer 时返回空；
+// 过滤掉已被 DeleteDocument 删除、但尚未从邻接表中清理掉的残留边，避免
+// greedySearchLayer/searchLayer 对着已不存在的文档取 Vector 导致 panic。
+func (s *HNSWVectorStore) layerNeighbors(id string, layer int) []string {
+	n, ok := s.nodes[id]
+	if !ok || layer >= len(n.Neighbors) {
+		return nil
+	}
+
+	neighbors := n.Neighbors[layer]
+	alive := make([]string, 0, len(neighbors))
+	for _, nid := range neighbors {
+		if _, ok := s.documents[nid]; ok {
+			alive = append(alive, nid)
+		}
+	}
+	return alive
+}
+
+// selectNeighborsHeuristic 从候选集中挑选最多 m 个邻居：按与目标的相似度从高到低考察
+// 候选点，若它与某个已选邻居的相似度高于它与目标的相似度（离已选邻居比离目标更近）
+// 就丢弃它，避免图退化成星形结构、保持可导航性。
+func (s *HNSWVectorStore) selectNeighborsHeuristic(query []float64, candidates []candidate, m int) []string {
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+
+	selected := make([]candidate, 0, m)
+	for _, c := range candidates {
+		if len(selected) >= m {
+			break
+		}
+		keep := true
+		for _, sel := range selected {
+			if CosineSimilarity(s.documents[c.id].Vector, s.documents[sel.id].Vector) > c.score {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			selected = append(selected, c)
+		}
+	}
+
+	ids := make([]string, len(selected))
+	for i, c := range selected {
+		ids[i] = c.id
+	}
+	return ids
+}
+
+// Search 检索 Top-K 最相似文档。文档数低于 hnswBruteForceThreshold 时退化为暴力扫描。
+func (s *HNSWVectorStore) Search(queryVector []float64, topK int, minScore float64) ([]SearchResult, error) {
+	return s.SearchWithFilter(queryVector, topK, minScore, nil)
+}
+
+// hnswFilterEfMultiplier 带元数据过滤时按该倍数放大 ef，在候选扩展阶段多取一些
+// 候选点再过滤，避免过滤条件命中率低时 topK 还没填满就提前截断。
+const hnswFilterEfMultiplier = 4
+
+// SearchWithFilter 与 Search 相同，但只在满足 filter 的文档里选 Top-K。
+// 过滤发生在候选扩展阶段（放大 ef 后过滤），而不是对最终 Top-K 结果做事后过滤，
+// 这样能在 filter 命中率较低时依然保持较高的召回率。
+func (s *HNSWVectorStore) SearchWithFilter(queryVector []float64, topK int, minScore float64, filter MetadataFilter) ([]SearchResult, error) {
+	if len(queryVector) == 0 {
+		return nil, fmt.Errorf("query vector cannot be empty")
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.documents) < hnswBruteForceThreshold || s.entryID == "" {
+		return s.bruteForceSearch(queryVector, topK, minScore, filter), nil
+	}
+
+	ef := s.cfg.EfSearch
+	if ef < topK {
+		ef = topK
+	}
+	if len(filter) > 0 {
+		ef *= hnswFilterEfMultiplier
+	}
+
+	entry := s.entryID
+	for l := s.maxLevel; l > 0; l-- {
+		entry = s.greedySearchLayer(queryVector, entry, l)
+	}
+
+	candidates := s.searchLayer(queryVector, entry, ef, 0)
+
+	results := make([]SearchResult, 0, topK)
+	for _, c := range candidates {
+		if c.score < minScore {
+			continue
+		}
+		doc := s.documents[c.id]
+		if !filter.Matches(doc.Metadata) {
+			continue
+		}
+		results = append(results, SearchResult{Document: *doc, Score: c.score, Distance: 1 - c.score})
+		if len(results) >= topK {
+			break
+		}
+	}
+	return results, nil
+}
+
+// bruteForceSearch 小数据集下的暴力余弦扫描，保证冷启动阶段的召回率
+func (s *HNSWVectorStore) bruteForceSearch(queryVector []float64, topK int, minScore float64, filter MetadataFilter) []SearchResult {
+	results := make([]SearchResult, 0, len(s.documents))
+	for _, doc := range s.documents {
+		if !filter.Matches(doc.Metadata) {
+			continue
+		}
+		if score := CosineSimilarity(queryVector, doc.Vector); score >= minScore {
+			results = append(results, SearchResult{Document: *doc, Score: score, Distance: 1 - score})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > topK {
+		results = results[:topK]
+	}
+	return results
+}
+
+// GetDocument 按 ID 获取文档
+func (s *HNSWVectorStore) GetDocument(id string) (*Document, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	doc, ok := s.documents[id]
+	if !ok {
+		return nil, fmt.Errorf("document not found: %s", id)
+	}
+	return doc, nil
+}
+
+// ListIDsByMetadata 返回所有满足 filter 的文档 ID，不涉及图遍历
+func (s *HNSWVectorStore) ListIDsByMetadata(filter MetadataFilter) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ids []string
+	for id, doc := range s.documents {
+		if filter.Matches(doc.Metadata) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// DeleteDocument 删除文档。为保持图的连通性，这里只做逻辑删除（移出 documents/nodes），
+// 不主动清理其他节点指向它的邻接边；layerNeighbors 会在遍历时把指向已删除文档的边过滤掉，
+// 所以搜索不会再访问到它们。
+func (s *HNSWVectorStore) DeleteDocument(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.documents[id]; !ok {
+		return fmt.Errorf("document not found: %s", id)
+	}
+
+	delete(s.documents, id)
+	delete(s.nodes, id)
+
+	if s.entryID == id {
+		s.entryID = ""
+		s.maxLevel = -1
+		for otherID, n := range s.nodes {
+			if s.entryID == "" || n.Level > s.nodes[s.entryID].Level {
+				s.entryID = otherID
+			}
+		}
+		if s.entryID != "" {
+			s.maxLevel = s.nodes[s.entryID].Level
+		}
+	}
+
+	s.logger.Info("文档已从 HNSW 索引删除", zap.String("id", id))
+	return nil
+}
+
+// Count 获取文档总数
+func (s *HNSWVectorStore) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.documents)
+}
+
+// Clear 清空索引
+func (s *HNSWVectorStore) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.documents = make(map[string]*Document)
+	s.nodes = make(map[string]*hnswNode)
+	s.entryID = ""
+	s.maxLevel = -1
+	return nil
+}
+
+// hnswSnapshot 用于持久化的图+向量数据
+type hnswSnapshot struct {
+	Documents map[string]*Document `json:"documents"`
+	Nodes     map[string]*hnswNode `json:"nodes"`
+	EntryID   string               `json:"entryId"`
+	MaxLevel  int                  `json:"maxLevel"`
+}
+
+// SaveSnapshot 将图结构和向量原子性地写入磁盘（先写临时文件再 rename）
+func (s *HNSWVectorStore) SaveSnapshot(path string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.snapshotPath = path
+	return s.saveSnapshotLocked()
+}
+
+func (s *HNSWVectorStore) saveSnapshotLocked() error {
+	snap := hnswSnapshot{Documents: s.documents, Nodes: s.nodes, EntryID: s.entryID, MaxLevel: s.maxLevel}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("序列化 HNSW 快照失败: %w", err)
+	}
+
+	tmpPath := s.snapshotPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("写入临时快照文件失败: %w", err)
+	}
+	return os.Rename(tmpPath, s.snapshotPath)
+}
+
+// LoadSnapshot 从磁盘恢复图结构和向量
+func (s *HNSWVectorStore) LoadSnapshot(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var snap hnswSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("解析 HNSW 快照失败: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.documents = snap.Documents
+	s.nodes = snap.Nodes
+	s.entryID = snap.EntryID
+	s.maxLevel = snap.MaxLevel
+	if s.documents == nil {
+		s.documents = make(map[string]*Document)
+	}
+	if s.nodes == nil {
+		s.nodes = make(map[string]*hnswNode)
+	}
+
+	s.logger.Info("HNSW 快照已加载", zap.String("path", path), zap.Int("docCount", len(s.documents)))
+	return nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
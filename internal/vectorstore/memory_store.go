@@ -24,7 +24,8 @@ type SearchResult struct {
 	Distance float64  // 向量距离
 }
 
-// MemoryVectorStore 内存向量存储（简化版）
+// MemoryVectorStore 内存向量存储（简化版），实现 VectorStore 接口。
+// 进程重启后数据会丢失，适合开发调试；生产环境请使用 BoltVectorStore 或 QdrantVectorStore。
 type MemoryVectorStore struct {
 	documents map[string]*Document // 文档存储
 	mu        sync.RWMutex         // 读写锁
@@ -69,6 +70,11 @@ func (s *MemoryVectorStore) AddDocuments(docs []Document) error {
 
 // Search 向量检索（返回 Top-K 最相似的文档）
 func (s *MemoryVectorStore) Search(queryVector []float64, topK int, minScore float64) ([]SearchResult, error) {
+	return s.SearchWithFilter(queryVector, topK, minScore, nil)
+}
+
+// SearchWithFilter 向量检索，候选文档先按 filter 过滤再参与排序和 Top-K 截断
+func (s *MemoryVectorStore) SearchWithFilter(queryVector []float64, topK int, minScore float64, filter MetadataFilter) ([]SearchResult, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -81,10 +87,13 @@ func (s *MemoryVectorStore) Search(queryVector []float64, topK int, minScore flo
 		zap.Int("topK", topK),
 		zap.Float64("minScore", minScore))
 
-	// 计算所有文档的相似度
+	// 计算所有满足过滤条件的文档的相似度
 	results := make([]SearchResult, 0, len(s.documents))
 	for _, doc := range s.documents {
-		score := cosineSimilarity(queryVector, doc.Vector)
+		if !filter.Matches(doc.Metadata) {
+			continue
+		}
+		score := CosineSimilarity(queryVector, doc.Vector)
 		if score >= minScore {
 			results = append(results, SearchResult{
 				Document: *doc,
@@ -123,6 +132,20 @@ func (s *MemoryVectorStore) GetDocument(id string) (*Document, error) {
 	return doc, nil
 }
 
+// ListIDsByMetadata 返回所有满足 filter 的文档 ID，不参与相似度计算
+func (s *MemoryVectorStore) ListIDsByMetadata(filter MetadataFilter) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ids []string
+	for id, doc := range s.documents {
+		if filter.Matches(doc.Metadata) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
 // DeleteDocument 删除文档
 func (s *MemoryVectorStore) DeleteDocument(id string) error {
 	s.mu.Lock()
@@ -145,15 +168,17 @@ func (s *MemoryVectorStore) Count() int {
 }
 
 // Clear 清空所有文档
-func (s *MemoryVectorStore) Clear() {
+func (s *MemoryVectorStore) Clear() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.documents = make(map[string]*Document)
 	s.logger.Info("向量存储已清空")
+	return nil
 }
 
-// cosineSimilarity 计算余弦相似度（0-1，越高越相似）
-func cosineSimilarity(a, b []float64) float64 {
+// CosineSimilarity 计算余弦相似度（0-1，越高越相似）。导出给 service 包复用，
+// 避免上层（如 BuildContext 的去重逻辑）重新实现一遍相同的向量数学。
+func CosineSimilarity(a, b []float64) float64 {
 	if len(a) != len(b) {
 		return 0
 	}
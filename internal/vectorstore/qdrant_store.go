@@ -0,0 +1,254 @@
+package vectorstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// QdrantVectorStore 基于 Qdrant REST API 的向量存储，适合知识库增长到
+// 十万级以上文档、需要独立部署和水平扩容的生产场景。
+type QdrantVectorStore struct {
+	baseURL    string
+	collection string
+	httpClient *http.Client
+	logger     *zap.Logger
+}
+
+// NewQdrantVectorStore 创建 Qdrant 向量存储客户端
+func NewQdrantVectorStore(baseURL, collection string, logger *zap.Logger) *QdrantVectorStore {
+	return &QdrantVectorStore{
+		baseURL:    baseURL,
+		collection: collection,
+		httpClient: &http.Client{},
+		logger:     logger,
+	}
+}
+
+type qdrantPoint struct {
+	ID      string                 `json:"id"`
+	Vector  []float64              `json:"vector"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
+// AddDocument 添加单个文档
+func (s *QdrantVectorStore) AddDocument(doc Document) error {
+	return s.AddDocuments([]Document{doc})
+}
+
+// AddDocuments 批量将文档写入 Qdrant（upsert）
+func (s *QdrantVectorStore) AddDocuments(docs []Document) error {
+	points := make([]qdrantPoint, len(docs))
+	for i, doc := range docs {
+		payload := map[string]interface{}{"content": doc.Content}
+		for k, v := range doc.Metadata {
+			payload[k] = v
+		}
+		points[i] = qdrantPoint{ID: doc.ID, Vector: doc.Vector, Payload: payload}
+	}
+
+	return s.do(http.MethodPut, fmt.Sprintf("/collections/%s/points?wait=true", s.collection),
+		map[string]interface{}{"points": points}, nil)
+}
+
+type qdrantSearchResponse struct {
+	Result []struct {
+		ID      string                 `json:"id"`
+		Score   float64                `json:"score"`
+		Payload map[string]interface{} `json:"payload"`
+		Vector  []float64              `json:"vector"`
+	} `json:"result"`
+}
+
+// Search 调用 Qdrant 的向量检索接口，返回 Top-K 最相似的文档
+func (s *QdrantVectorStore) Search(queryVector []float64, topK int, minScore float64) ([]SearchResult, error) {
+	return s.SearchWithFilter(queryVector, topK, minScore, nil)
+}
+
+// SearchWithFilter 与 Search 相同，额外把 filter 转换为 Qdrant 原生的 payload 过滤条件，
+// 由 Qdrant 在候选扩展阶段完成过滤，而不是取回结果后再过滤。
+func (s *QdrantVectorStore) SearchWithFilter(queryVector []float64, topK int, minScore float64, filter MetadataFilter) ([]SearchResult, error) {
+	reqBody := map[string]interface{}{
+		"vector":          queryVector,
+		"limit":           topK,
+		"score_threshold": minScore,
+		"with_payload":    true,
+		"with_vector":     true,
+	}
+
+	if len(filter) > 0 {
+		must := make([]map[string]interface{}, 0, len(filter))
+		for k, v := range filter {
+			must = append(must, map[string]interface{}{
+				"key":   k,
+				"match": map[string]interface{}{"value": v},
+			})
+		}
+		reqBody["filter"] = map[string]interface{}{"must": must}
+	}
+
+	var searchResp qdrantSearchResponse
+	if err := s.do(http.MethodPost, fmt.Sprintf("/collections/%s/points/search", s.collection), reqBody, &searchResp); err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(searchResp.Result))
+	for _, r := range searchResp.Result {
+		content, metadata := splitPayload(r.Payload)
+		results = append(results, SearchResult{
+			Document: Document{ID: r.ID, Content: content, Vector: r.Vector, Metadata: metadata},
+			Score:    r.Score,
+			Distance: 1 - r.Score,
+		})
+	}
+	return results, nil
+}
+
+// GetDocument 按 ID 获取文档
+func (s *QdrantVectorStore) GetDocument(id string) (*Document, error) {
+	var out struct {
+		Result struct {
+			ID      string                 `json:"id"`
+			Payload map[string]interface{} `json:"payload"`
+			Vector  []float64              `json:"vector"`
+		} `json:"result"`
+	}
+
+	if err := s.do(http.MethodGet, fmt.Sprintf("/collections/%s/points/%s", s.collection, id), nil, &out); err != nil {
+		return nil, err
+	}
+	if out.Result.ID == "" {
+		return nil, fmt.Errorf("document not found: %s", id)
+	}
+
+	content, metadata := splitPayload(out.Result.Payload)
+	return &Document{ID: out.Result.ID, Content: content, Vector: out.Result.Vector, Metadata: metadata}, nil
+}
+
+type qdrantScrollResponse struct {
+	Result struct {
+		Points []struct {
+			ID string `json:"id"`
+		} `json:"points"`
+	} `json:"result"`
+}
+
+// qdrantScrollLimit 单次 scroll 请求返回的最大点数，按来源批量删除的场景下
+// 单个来源的分片数远小于这个值，暂不做翻页
+const qdrantScrollLimit = 10000
+
+// ListIDsByMetadata 调用 Qdrant 的 scroll 接口按 payload 过滤条件列出文档 ID，
+// 不取向量/内容，用于按来源批量查找/删除
+func (s *QdrantVectorStore) ListIDsByMetadata(filter MetadataFilter) ([]string, error) {
+	reqBody := map[string]interface{}{
+		"limit":        qdrantScrollLimit,
+		"with_payload": false,
+		"with_vector":  false,
+	}
+	if len(filter) > 0 {
+		must := make([]map[string]interface{}, 0, len(filter))
+		for k, v := range filter {
+			must = append(must, map[string]interface{}{
+				"key":   k,
+				"match": map[string]interface{}{"value": v},
+			})
+		}
+		reqBody["filter"] = map[string]interface{}{"must": must}
+	}
+
+	var scrollResp qdrantScrollResponse
+	if err := s.do(http.MethodPost, fmt.Sprintf("/collections/%s/points/scroll", s.collection), reqBody, &scrollResp); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(scrollResp.Result.Points))
+	for _, p := range scrollResp.Result.Points {
+		ids = append(ids, p.ID)
+	}
+	return ids, nil
+}
+
+// DeleteDocument 按 ID 删除文档
+func (s *QdrantVectorStore) DeleteDocument(id string) error {
+	return s.do(http.MethodPost, fmt.Sprintf("/collections/%s/points/delete", s.collection),
+		map[string]interface{}{"points": []string{id}}, nil)
+}
+
+// Count 获取集合中的文档总数
+func (s *QdrantVectorStore) Count() int {
+	var out struct {
+		Result struct {
+			PointsCount int `json:"points_count"`
+		} `json:"result"`
+	}
+	if err := s.do(http.MethodGet, fmt.Sprintf("/collections/%s", s.collection), nil, &out); err != nil {
+		s.logger.Error("获取 Qdrant 集合信息失败", zap.Error(err))
+		return 0
+	}
+	return out.Result.PointsCount
+}
+
+// Clear 删除整个集合；本类型不会重新创建集合，之后写入前需先在 Qdrant 中重新建好该集合
+func (s *QdrantVectorStore) Clear() error {
+	return s.do(http.MethodDelete, fmt.Sprintf("/collections/%s", s.collection), nil, nil)
+}
+
+// do 发送一次 Qdrant REST 请求并将响应解析到 out（out 为 nil 时忽略响应体）
+func (s *QdrantVectorStore) do(method, path string, reqBody, out interface{}) error {
+	var bodyReader io.Reader
+	if reqBody != nil {
+		data, err := json.Marshal(reqBody)
+		if err != nil {
+			return fmt.Errorf("序列化请求失败: %w", err)
+		}
+		bodyReader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, s.baseURL+path, bodyReader)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求 Qdrant 失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Qdrant 返回错误 %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("解析响应失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// splitPayload 将 Qdrant payload 还原为 Document 的 content + string metadata
+func splitPayload(payload map[string]interface{}) (string, map[string]string) {
+	content := ""
+	metadata := make(map[string]string, len(payload))
+	for k, v := range payload {
+		if k == "content" {
+			content, _ = v.(string)
+			continue
+		}
+		if str, ok := v.(string); ok {
+			metadata[k] = str
+		}
+	}
+	return content, metadata
+}
@@ -0,0 +1,109 @@
+package vectorstore
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/supportbot/supportbot-go/internal/config"
+	"go.uber.org/zap"
+)
+
+// MetadataFilter 检索时的元数据过滤条件，所有 key=value 都命中才算匹配。
+// nil 或空 filter 表示不过滤。
+type MetadataFilter map[string]string
+
+// Matches 判断文档元数据是否满足过滤条件
+func (f MetadataFilter) Matches(metadata map[string]string) bool {
+	for k, v := range f {
+		if metadata[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// VectorStore 向量存储抽象，屏蔽内存、BoltDB、Qdrant 等具体后端的差异，
+// 使 KnowledgeService 可以在不同部署环境下复用同一套检索逻辑。
+type VectorStore interface {
+	// AddDocument 添加单个文档
+	AddDocument(doc Document) error
+	// AddDocuments 批量添加文档
+	AddDocuments(docs []Document) error
+	// Search 检索 Top-K 最相似的文档
+	Search(queryVector []float64, topK int, minScore float64) ([]SearchResult, error)
+	// SearchWithFilter 与 Search 相同，但只在满足 filter 的文档中挑选 Top-K。
+	// 过滤在候选扩展阶段完成，而不是对 Search 结果做事后截断，避免召回不足。
+	SearchWithFilter(queryVector []float64, topK int, minScore float64, filter MetadataFilter) ([]SearchResult, error)
+	// GetDocument 按 ID 获取文档
+	GetDocument(id string) (*Document, error)
+	// ListIDsByMetadata 返回所有满足 filter 的文档 ID，不做向量检索。用于按
+	// parent_id 等元数据批量查找文档（例如 DeleteBySource），相比在上层维护一份
+	// 内存映射，这里直接查的是存储本身，天然支持持久化后端重启后继续可用。
+	ListIDsByMetadata(filter MetadataFilter) ([]string, error)
+	// DeleteDocument 按 ID 删除文档
+	DeleteDocument(id string) error
+	// Count 获取文档总数
+	Count() int
+	// Clear 清空所有文档
+	Clear() error
+}
+
+// Driver 根据配置创建一个 VectorStore 实例
+type Driver func(cfg config.VectorStoreConfig, logger *zap.Logger) (VectorStore, error)
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]Driver)
+)
+
+// RegisterDriver 注册一个向量存储驱动，backend 为 config.VectorStoreConfig.Backend
+// 中使用的名字。内置的 memory/bolt/hnsw/qdrant 驱动在各自文件的 init() 中完成注册；
+// 外部存储（Milvus、pgvector 等）只需在引入包时调用 RegisterDriver，无需修改
+// NewVectorStore 或 KnowledgeService。同名重复注册会覆盖旧驱动。
+func RegisterDriver(backend string, driver Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	drivers[backend] = driver
+}
+
+// NewVectorStore 根据配置创建向量存储实例，未配置 backend 时默认使用内存存储
+func NewVectorStore(cfg config.VectorStoreConfig, logger *zap.Logger) (VectorStore, error) {
+	backend := cfg.Backend
+	if backend == "" {
+		backend = "memory"
+	}
+
+	driversMu.RLock()
+	driver, ok := drivers[backend]
+	driversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("不支持的向量存储后端: %s", backend)
+	}
+
+	return driver(cfg, logger)
+}
+
+func init() {
+	RegisterDriver("memory", func(cfg config.VectorStoreConfig, logger *zap.Logger) (VectorStore, error) {
+		return NewMemoryVectorStore(logger), nil
+	})
+
+	RegisterDriver("bolt", func(cfg config.VectorStoreConfig, logger *zap.Logger) (VectorStore, error) {
+		if cfg.Bolt.Path == "" {
+			return nil, fmt.Errorf("bolt 向量存储需要配置 vectorStore.bolt.path")
+		}
+		return NewBoltVectorStore(cfg.Bolt.Path, logger)
+	})
+
+	RegisterDriver("hnsw", func(cfg config.VectorStoreConfig, logger *zap.Logger) (VectorStore, error) {
+		hnswCfg := HNSWConfig{M: cfg.HNSW.M, EfConstruction: cfg.HNSW.EfConstruction, EfSearch: cfg.HNSW.EfSearch}
+		return NewHNSWVectorStore(hnswCfg, cfg.HNSW.SnapshotPath, logger), nil
+	})
+
+	RegisterDriver("qdrant", func(cfg config.VectorStoreConfig, logger *zap.Logger) (VectorStore, error) {
+		if cfg.Qdrant.BaseURL == "" || cfg.Qdrant.Collection == "" {
+			return nil, fmt.Errorf("qdrant 向量存储需要配置 vectorStore.qdrant.baseUrl 和 collection")
+		}
+		return NewQdrantVectorStore(cfg.Qdrant.BaseURL, cfg.Qdrant.Collection, logger), nil
+	})
+}
@@ -0,0 +1,68 @@
+package vectorstore
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Tokenizer 将文本切分为检索用的 token，BM25Index 依赖它构建倒排索引。
+// 默认实现为 Tokenize；调用方可以实现自己的分词器（例如接入 jieba 做真正的
+// 中文分词）并通过 NewBM25IndexWithTokenizer 替换。
+type Tokenizer func(text string) []string
+
+// Tokenize 将文本切分为检索用的 token。英文/数字按空白和标点分词，转小写后
+// 做简单的后缀词干化（stemming）；中日韩文字没有天然分词边界，这里退化为
+// 相邻两字的 bigram，在没有接入专业分词器（如 jieba）的情况下仍能覆盖大多数
+// 中文知识库的检索场景。
+func Tokenize(text string) []string {
+	runes := []rune(text)
+	tokens := make([]string, 0, len(runes))
+	var buf []rune
+
+	flushWord := func() {
+		if len(buf) > 0 {
+			tokens = append(tokens, stem(strings.ToLower(string(buf))))
+			buf = buf[:0]
+		}
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case isCJK(r):
+			flushWord()
+			if i+1 < len(runes) && isCJK(runes[i+1]) {
+				tokens = append(tokens, string(runes[i:i+2]))
+			} else {
+				tokens = append(tokens, string(r))
+			}
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			buf = append(buf, r)
+		default:
+			flushWord()
+		}
+	}
+	flushWord()
+
+	return tokens
+}
+
+// isCJK 判断是否为中日韩统一表意文字（覆盖简体/繁体中文的绝大多数场景）
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r)
+}
+
+// stemSuffixes 按长度从长到短排列，避免 "ies" 被 "s" 提前截断
+var stemSuffixes = []string{"ing", "ies", "ied", "ed", "es", "s"}
+
+// stem 对英文词做极简后缀词干化，去掉常见的复数/时态后缀，让 "orders"、
+// "ordered"、"ordering" 等能够命中同一个 BM25 term。只在词干保留长度 >= 3 时
+// 才剥离后缀，避免 "is"、"as" 这类短词被误处理。
+func stem(word string) string {
+	for _, suffix := range stemSuffixes {
+		if strings.HasSuffix(word, suffix) && len(word)-len(suffix) >= 3 {
+			return word[:len(word)-len(suffix)]
+		}
+	}
+	return word
+}